@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_pr_assignment_queue_queued_at;
+DROP TABLE IF EXISTS pr_assignment_queue;
+ALTER TABLE users DROP COLUMN IF EXISTS max_open_reviews;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE pr_reviewers DROP CONSTRAINT IF EXISTS pr_reviewers_review_state_check;
+ALTER TABLE pr_reviewers DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE pr_reviewers DROP COLUMN IF EXISTS first_reviewed_at;
+ALTER TABLE pr_reviewers DROP COLUMN IF EXISTS review_state;
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS review_state VARCHAR(50) NOT NULL DEFAULT 'PENDING';
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS first_reviewed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE pr_reviewers ADD CONSTRAINT pr_reviewers_review_state_check
+    CHECK (review_state IN ('PENDING', 'APPROVED', 'CHANGES_REQUESTED', 'COMMENTED'));
\ No newline at end of file
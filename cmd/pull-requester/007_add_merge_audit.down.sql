@@ -0,0 +1,2 @@
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS force_merged;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS merged_by;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS reviewer_reassignments (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    old_reviewer_id VARCHAR(255) NOT NULL,
+    new_reviewer_id VARCHAR(255),
+    reassigned_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviewer_reassignments_old ON reviewer_reassignments(old_reviewer_id, reassigned_at);
+
+INSERT INTO reviewer_reassignments (pull_request_id, old_reviewer_id, new_reviewer_id, reassigned_at)
+SELECT pull_request_id, old_reviewer_id, new_reviewer_id, created_at
+FROM assignment_events
+WHERE event_type IN ('REASSIGNED', 'UNASSIGNED') AND old_reviewer_id IS NOT NULL
+ORDER BY id;
+
+DROP TABLE IF EXISTS assignment_events;
+DROP FUNCTION IF EXISTS reject_assignment_event_update();
\ No newline at end of file
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS assignment_events (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id VARCHAR(255) NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    actor VARCHAR(255),
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    old_reviewer_id VARCHAR(255),
+    new_reviewer_id VARCHAR(255),
+    old_status VARCHAR(50),
+    new_status VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (event_type IN ('ASSIGNED', 'UNASSIGNED', 'REASSIGNED', 'MERGED', 'STATUS_CHANGED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_assignment_events_pr ON assignment_events(pull_request_id, id);
+CREATE INDEX IF NOT EXISTS idx_assignment_events_old_reviewer ON assignment_events(old_reviewer_id, created_at);
+
+CREATE OR REPLACE FUNCTION reject_assignment_event_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'assignment_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS assignment_events_append_only ON assignment_events;
+CREATE TRIGGER assignment_events_append_only
+    BEFORE UPDATE ON assignment_events
+    FOR EACH ROW EXECUTE FUNCTION reject_assignment_event_update();
+
+INSERT INTO assignment_events (pull_request_id, event_type, reason, old_reviewer_id, new_reviewer_id, created_at)
+SELECT pull_request_id,
+       CASE WHEN new_reviewer_id IS NULL THEN 'UNASSIGNED' ELSE 'REASSIGNED' END,
+       'reassigned', old_reviewer_id, new_reviewer_id, reassigned_at
+FROM reviewer_reassignments
+ORDER BY id;
+
+DROP TABLE IF EXISTS reviewer_reassignments;
\ No newline at end of file
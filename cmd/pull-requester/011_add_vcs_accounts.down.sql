@@ -0,0 +1 @@
+DROP TABLE IF EXISTS vcs_accounts;
\ No newline at end of file
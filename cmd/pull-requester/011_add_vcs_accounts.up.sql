@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS vcs_accounts (
+    provider VARCHAR(50) NOT NULL,
+    login VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, login)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vcs_accounts_user ON vcs_accounts(user_id);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE pr_reviewers DROP COLUMN IF EXISTS source;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS changed_files;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS repository_id;
+DROP TABLE IF EXISTS codeowners;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS codeowners (
+    repository_id VARCHAR(255) PRIMARY KEY,
+    content TEXT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS repository_id VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS changed_files JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_pull_requests_repository_id;
+DROP TABLE IF EXISTS repository_teams;
+DROP TABLE IF EXISTS repositories;
\ No newline at end of file
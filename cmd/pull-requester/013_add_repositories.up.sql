@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS repositories (
+    repository_id VARCHAR(255) PRIMARY KEY,
+    reviewer_count INTEGER CHECK (reviewer_count >= 0),
+    strategy TEXT NOT NULL DEFAULT '',
+    merge_policy JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS repository_teams (
+    repository_id VARCHAR(255) REFERENCES repositories(repository_id) ON DELETE CASCADE,
+    team_name VARCHAR(255) REFERENCES teams(team_name) ON DELETE CASCADE,
+    priority INTEGER NOT NULL,
+    PRIMARY KEY (repository_id, team_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pull_requests_repository_id ON pull_requests(repository_id);
\ No newline at end of file
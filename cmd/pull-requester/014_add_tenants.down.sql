@@ -0,0 +1,83 @@
+-- keys lose the tenant again, so only the default tenant can be kept
+DELETE FROM pull_requests WHERE tenant_id <> 'default';
+DELETE FROM vcs_accounts WHERE tenant_id <> 'default';
+DELETE FROM users WHERE tenant_id <> 'default';
+DELETE FROM repositories WHERE tenant_id <> 'default';
+DELETE FROM codeowners WHERE tenant_id <> 'default';
+DELETE FROM teams WHERE tenant_id <> 'default';
+DELETE FROM webhooks WHERE tenant_id <> 'default';
+DELETE FROM outbox WHERE tenant_id <> 'default';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_users_team;
+ALTER TABLE pull_requests DROP CONSTRAINT IF EXISTS fk_pr_author;
+ALTER TABLE pr_reviewers DROP CONSTRAINT IF EXISTS fk_pr_reviewers_pr;
+ALTER TABLE pr_reviewers DROP CONSTRAINT IF EXISTS fk_pr_reviewers_user;
+ALTER TABLE pr_assignment_queue DROP CONSTRAINT IF EXISTS fk_pr_assignment_queue_pr;
+ALTER TABLE team_fallbacks DROP CONSTRAINT IF EXISTS fk_team_fallbacks_team;
+ALTER TABLE team_fallbacks DROP CONSTRAINT IF EXISTS fk_team_fallbacks_fallback;
+ALTER TABLE assignment_events DROP CONSTRAINT IF EXISTS fk_assignment_events_pr;
+ALTER TABLE vcs_accounts DROP CONSTRAINT IF EXISTS fk_vcs_accounts_user;
+ALTER TABLE repository_teams DROP CONSTRAINT IF EXISTS fk_repository_teams_repository;
+ALTER TABLE repository_teams DROP CONSTRAINT IF EXISTS fk_repository_teams_team;
+
+ALTER TABLE teams DROP CONSTRAINT IF EXISTS teams_pkey;
+ALTER TABLE teams ADD PRIMARY KEY (team_name);
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_pkey;
+ALTER TABLE users ADD PRIMARY KEY (user_id);
+ALTER TABLE pull_requests DROP CONSTRAINT IF EXISTS pull_requests_pkey;
+ALTER TABLE pull_requests ADD PRIMARY KEY (pull_request_id);
+ALTER TABLE pr_reviewers DROP CONSTRAINT IF EXISTS pr_reviewers_pkey;
+ALTER TABLE pr_reviewers ADD PRIMARY KEY (pull_request_id, reviewer_id);
+ALTER TABLE pr_assignment_queue DROP CONSTRAINT IF EXISTS pr_assignment_queue_pkey;
+ALTER TABLE pr_assignment_queue ADD PRIMARY KEY (pull_request_id);
+ALTER TABLE team_fallbacks DROP CONSTRAINT IF EXISTS team_fallbacks_pkey;
+ALTER TABLE team_fallbacks ADD PRIMARY KEY (team_name, fallback_team_name);
+ALTER TABLE vcs_accounts DROP CONSTRAINT IF EXISTS vcs_accounts_pkey;
+ALTER TABLE vcs_accounts ADD PRIMARY KEY (provider, login);
+ALTER TABLE codeowners DROP CONSTRAINT IF EXISTS codeowners_pkey;
+ALTER TABLE codeowners ADD PRIMARY KEY (repository_id);
+ALTER TABLE repositories DROP CONSTRAINT IF EXISTS repositories_pkey;
+ALTER TABLE repositories ADD PRIMARY KEY (repository_id);
+ALTER TABLE repository_teams DROP CONSTRAINT IF EXISTS repository_teams_pkey;
+ALTER TABLE repository_teams ADD PRIMARY KEY (repository_id, team_name);
+
+ALTER TABLE users ADD CONSTRAINT fk_users_team
+    FOREIGN KEY (team_name) REFERENCES teams(team_name) ON DELETE CASCADE;
+ALTER TABLE pull_requests ADD CONSTRAINT fk_pr_author
+    FOREIGN KEY (author_id) REFERENCES users(user_id);
+ALTER TABLE pr_reviewers ADD CONSTRAINT fk_pr_reviewers_pr
+    FOREIGN KEY (pull_request_id) REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE;
+ALTER TABLE pr_reviewers ADD CONSTRAINT fk_pr_reviewers_user
+    FOREIGN KEY (reviewer_id) REFERENCES users(user_id);
+ALTER TABLE pr_assignment_queue ADD CONSTRAINT pr_assignment_queue_pull_request_id_fkey
+    FOREIGN KEY (pull_request_id) REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE;
+ALTER TABLE team_fallbacks ADD CONSTRAINT team_fallbacks_team_name_fkey
+    FOREIGN KEY (team_name) REFERENCES teams(team_name) ON DELETE CASCADE;
+ALTER TABLE team_fallbacks ADD CONSTRAINT team_fallbacks_fallback_team_name_fkey
+    FOREIGN KEY (fallback_team_name) REFERENCES teams(team_name) ON DELETE CASCADE;
+ALTER TABLE assignment_events ADD CONSTRAINT assignment_events_pull_request_id_fkey
+    FOREIGN KEY (pull_request_id) REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE;
+ALTER TABLE vcs_accounts ADD CONSTRAINT vcs_accounts_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE;
+ALTER TABLE repository_teams ADD CONSTRAINT repository_teams_repository_id_fkey
+    FOREIGN KEY (repository_id) REFERENCES repositories(repository_id) ON DELETE CASCADE;
+ALTER TABLE repository_teams ADD CONSTRAINT repository_teams_team_name_fkey
+    FOREIGN KEY (team_name) REFERENCES teams(team_name) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_webhooks_tenant;
+DROP INDEX IF EXISTS idx_users_team_active;
+CREATE INDEX IF NOT EXISTS idx_users_team_active ON users(team_name, is_active);
+
+ALTER TABLE teams DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE pr_reviewers DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE pr_assignment_queue DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE team_fallbacks DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE assignment_events DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE outbox DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE vcs_accounts DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE codeowners DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE repositories DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE repository_teams DROP COLUMN IF EXISTS tenant_id;
\ No newline at end of file
@@ -0,0 +1,89 @@
+-- existing rows all belong to the default tenant
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE pr_assignment_queue ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE team_fallbacks ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE assignment_events ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE vcs_accounts ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE codeowners ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+ALTER TABLE repository_teams ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT 'default';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_users_team;
+ALTER TABLE pull_requests DROP CONSTRAINT IF EXISTS fk_pr_author;
+ALTER TABLE pr_reviewers DROP CONSTRAINT IF EXISTS fk_pr_reviewers_pr;
+ALTER TABLE pr_reviewers DROP CONSTRAINT IF EXISTS fk_pr_reviewers_user;
+ALTER TABLE pr_assignment_queue DROP CONSTRAINT IF EXISTS pr_assignment_queue_pull_request_id_fkey;
+ALTER TABLE team_fallbacks DROP CONSTRAINT IF EXISTS team_fallbacks_team_name_fkey;
+ALTER TABLE team_fallbacks DROP CONSTRAINT IF EXISTS team_fallbacks_fallback_team_name_fkey;
+ALTER TABLE assignment_events DROP CONSTRAINT IF EXISTS assignment_events_pull_request_id_fkey;
+ALTER TABLE vcs_accounts DROP CONSTRAINT IF EXISTS vcs_accounts_user_id_fkey;
+ALTER TABLE repository_teams DROP CONSTRAINT IF EXISTS repository_teams_repository_id_fkey;
+ALTER TABLE repository_teams DROP CONSTRAINT IF EXISTS repository_teams_team_name_fkey;
+
+ALTER TABLE teams DROP CONSTRAINT IF EXISTS teams_pkey;
+ALTER TABLE teams ADD PRIMARY KEY (tenant_id, team_name);
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_pkey;
+ALTER TABLE users ADD PRIMARY KEY (tenant_id, user_id);
+ALTER TABLE pull_requests DROP CONSTRAINT IF EXISTS pull_requests_pkey;
+ALTER TABLE pull_requests ADD PRIMARY KEY (tenant_id, pull_request_id);
+ALTER TABLE pr_reviewers DROP CONSTRAINT IF EXISTS pr_reviewers_pkey;
+ALTER TABLE pr_reviewers ADD PRIMARY KEY (tenant_id, pull_request_id, reviewer_id);
+ALTER TABLE pr_assignment_queue DROP CONSTRAINT IF EXISTS pr_assignment_queue_pkey;
+ALTER TABLE pr_assignment_queue ADD PRIMARY KEY (tenant_id, pull_request_id);
+ALTER TABLE team_fallbacks DROP CONSTRAINT IF EXISTS team_fallbacks_pkey;
+ALTER TABLE team_fallbacks ADD PRIMARY KEY (tenant_id, team_name, fallback_team_name);
+ALTER TABLE vcs_accounts DROP CONSTRAINT IF EXISTS vcs_accounts_pkey;
+ALTER TABLE vcs_accounts ADD PRIMARY KEY (tenant_id, provider, login);
+ALTER TABLE codeowners DROP CONSTRAINT IF EXISTS codeowners_pkey;
+ALTER TABLE codeowners ADD PRIMARY KEY (tenant_id, repository_id);
+ALTER TABLE repositories DROP CONSTRAINT IF EXISTS repositories_pkey;
+ALTER TABLE repositories ADD PRIMARY KEY (tenant_id, repository_id);
+ALTER TABLE repository_teams DROP CONSTRAINT IF EXISTS repository_teams_pkey;
+ALTER TABLE repository_teams ADD PRIMARY KEY (tenant_id, repository_id, team_name);
+
+ALTER TABLE users ADD CONSTRAINT fk_users_team
+    FOREIGN KEY (tenant_id, team_name) REFERENCES teams(tenant_id, team_name) ON DELETE CASCADE;
+ALTER TABLE pull_requests ADD CONSTRAINT fk_pr_author
+    FOREIGN KEY (tenant_id, author_id) REFERENCES users(tenant_id, user_id);
+ALTER TABLE pr_reviewers ADD CONSTRAINT fk_pr_reviewers_pr
+    FOREIGN KEY (tenant_id, pull_request_id) REFERENCES pull_requests(tenant_id, pull_request_id) ON DELETE CASCADE;
+ALTER TABLE pr_reviewers ADD CONSTRAINT fk_pr_reviewers_user
+    FOREIGN KEY (tenant_id, reviewer_id) REFERENCES users(tenant_id, user_id);
+ALTER TABLE pr_assignment_queue ADD CONSTRAINT fk_pr_assignment_queue_pr
+    FOREIGN KEY (tenant_id, pull_request_id) REFERENCES pull_requests(tenant_id, pull_request_id) ON DELETE CASCADE;
+ALTER TABLE team_fallbacks ADD CONSTRAINT fk_team_fallbacks_team
+    FOREIGN KEY (tenant_id, team_name) REFERENCES teams(tenant_id, team_name) ON DELETE CASCADE;
+ALTER TABLE team_fallbacks ADD CONSTRAINT fk_team_fallbacks_fallback
+    FOREIGN KEY (tenant_id, fallback_team_name) REFERENCES teams(tenant_id, team_name) ON DELETE CASCADE;
+ALTER TABLE assignment_events ADD CONSTRAINT fk_assignment_events_pr
+    FOREIGN KEY (tenant_id, pull_request_id) REFERENCES pull_requests(tenant_id, pull_request_id) ON DELETE CASCADE;
+ALTER TABLE vcs_accounts ADD CONSTRAINT fk_vcs_accounts_user
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users(tenant_id, user_id) ON DELETE CASCADE;
+ALTER TABLE repository_teams ADD CONSTRAINT fk_repository_teams_repository
+    FOREIGN KEY (tenant_id, repository_id) REFERENCES repositories(tenant_id, repository_id) ON DELETE CASCADE;
+ALTER TABLE repository_teams ADD CONSTRAINT fk_repository_teams_team
+    FOREIGN KEY (tenant_id, team_name) REFERENCES teams(tenant_id, team_name) ON DELETE CASCADE;
+
+-- new rows have to name their tenant
+ALTER TABLE teams ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE pull_requests ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE pr_reviewers ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE pr_assignment_queue ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE team_fallbacks ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE assignment_events ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE outbox ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE webhooks ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE vcs_accounts ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE codeowners ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE repositories ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE repository_teams ALTER COLUMN tenant_id DROP DEFAULT;
+
+DROP INDEX IF EXISTS idx_users_team_active;
+CREATE INDEX IF NOT EXISTS idx_users_team_active ON users(tenant_id, team_name, is_active);
+CREATE INDEX IF NOT EXISTS idx_webhooks_tenant ON webhooks(tenant_id);
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(255) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    name TEXT NOT NULL DEFAULT '',
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'team-lead', 'member', 'read-only')),
+    user_id VARCHAR(255),
+    team_name VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT fk_api_keys_user FOREIGN KEY (tenant_id, user_id) REFERENCES users(tenant_id, user_id) ON DELETE CASCADE,
+    CONSTRAINT fk_api_keys_team FOREIGN KEY (tenant_id, team_name) REFERENCES teams(tenant_id, team_name) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant ON api_keys(tenant_id);
\ No newline at end of file
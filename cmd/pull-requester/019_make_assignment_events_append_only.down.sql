@@ -0,0 +1,17 @@
+ALTER TABLE assignment_events DROP CONSTRAINT IF EXISTS fk_assignment_events_pr;
+ALTER TABLE assignment_events ADD CONSTRAINT fk_assignment_events_pr
+    FOREIGN KEY (tenant_id, pull_request_id) REFERENCES pull_requests(tenant_id, pull_request_id) ON DELETE CASCADE;
+
+CREATE OR REPLACE FUNCTION reject_assignment_event_update() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'assignment_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS assignment_events_no_truncate ON assignment_events;
+DROP TRIGGER IF EXISTS assignment_events_append_only ON assignment_events;
+CREATE TRIGGER assignment_events_append_only
+    BEFORE UPDATE ON assignment_events
+    FOR EACH ROW EXECUTE FUNCTION reject_assignment_event_update();
+
+DROP FUNCTION IF EXISTS reject_assignment_event_change();
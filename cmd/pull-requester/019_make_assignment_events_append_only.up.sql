@@ -0,0 +1,22 @@
+CREATE OR REPLACE FUNCTION reject_assignment_event_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'assignment_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS assignment_events_append_only ON assignment_events;
+CREATE TRIGGER assignment_events_append_only
+    BEFORE UPDATE OR DELETE ON assignment_events
+    FOR EACH ROW EXECUTE FUNCTION reject_assignment_event_change();
+
+DROP TRIGGER IF EXISTS assignment_events_no_truncate ON assignment_events;
+CREATE TRIGGER assignment_events_no_truncate
+    BEFORE TRUNCATE ON assignment_events
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_assignment_event_change();
+
+DROP FUNCTION IF EXISTS reject_assignment_event_update();
+
+-- deleting a PR must not take its history with it
+ALTER TABLE assignment_events DROP CONSTRAINT IF EXISTS fk_assignment_events_pr;
+ALTER TABLE assignment_events ADD CONSTRAINT fk_assignment_events_pr
+    FOREIGN KEY (tenant_id, pull_request_id) REFERENCES pull_requests(tenant_id, pull_request_id) ON DELETE RESTRICT;
@@ -12,10 +12,16 @@ import (
 	"syscall"
 	"time"
 	"github.com/shmul/avito-task/config"
+	"github.com/shmul/avito-task/internal/domain/entity"
 	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/http/handlers"
+	"github.com/shmul/avito-task/internal/infrastructure/http/middleware"
 	"github.com/shmul/avito-task/internal/infrastructure/http/server"
+	"github.com/shmul/avito-task/internal/infrastructure/oidc"
+	"github.com/shmul/avito-task/internal/infrastructure/ratelimit"
 	"github.com/shmul/avito-task/internal/infrastructure/storage/migrations"
 	"github.com/shmul/avito-task/internal/infrastructure/storage/postgres"
+	"github.com/shmul/avito-task/internal/infrastructure/webhook"
 )
 
 //go:embed *.sql
@@ -56,20 +62,107 @@ func main() {
 	}
 	log.Info("migrations completed successfully")
 
-	userRepo := postgres.NewUserRepository(db.DB())
-	teamRepo := postgres.NewTeamRepository(db.DB())
-	prRepo := postgres.NewPRRepository(db.DB())
+	userRepo := postgres.NewUserRepository(db)
+	teamRepo := postgres.NewTeamRepository(db)
+	prRepo := postgres.NewPRRepository(db)
+	webhookRepo := postgres.NewWebhookRepository(db)
+	codeownersRepo := postgres.NewCodeownersRepository(db)
+	repositoryRepo := postgres.NewRepositoryRepository(db)
+	apiKeyRepo := postgres.NewAPIKeyRepository(db)
+	idempotencyRepo := postgres.NewIdempotencyRepository(db)
+	transactor := postgres.NewTransactor(db)
 
-	userService := service.NewUserService(userRepo, teamRepo)
 	teamService := service.NewTeamService(teamRepo, userRepo)
-	prService := service.NewPRService(prRepo, userRepo, teamRepo, &service.PRServiceConfig{
-		ReviewerCount: cfg.App.ReviewerCount,
-		RandomSeed:    int64(cfg.App.RandomSeed),
-	})
+	teamMergePolicies := make(map[string]service.MergePolicy, len(cfg.App.TeamMergePolicies))
+	for team, policy := range cfg.App.TeamMergePolicies {
+		teamMergePolicies[team] = mergePolicy(policy)
+	}
+
+	prService, err := service.NewPRService(prRepo, userRepo, teamRepo, codeownersRepo, repositoryRepo, transactor, &service.PRServiceConfig{
+		ReviewerCount:     cfg.App.ReviewerCount,
+		RandomSeed:        int64(cfg.App.RandomSeed),
+		Strategy:          cfg.App.ReviewerStrategy,
+		TeamStrategies:    cfg.App.TeamStrategies,
+		ReviewerWeights:   cfg.App.ReviewerWeights,
+		CapacityOverflow:  cfg.App.CapacityOverflow,
+		MergePolicy:       mergePolicy(cfg.App.MergePolicy),
+		TeamMergePolicies: teamMergePolicies,
+		ReviewerGroups:    cfg.App.ReviewerGroups,
+	}, log)
+	if err != nil {
+		log.Error("failed to init pr service", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	userService := service.NewUserService(userRepo, teamRepo, prService, transactor)
+	statsService := service.NewStatsService(prRepo)
+	metricsService := service.NewMetricsService(prRepo)
+	webhookService := service.NewWebhookService(webhookRepo)
+	vcsService := service.NewVCSService(prService, prRepo, userRepo)
+	codeownersService := service.NewCodeownersService(codeownersRepo)
+	repositoryService := service.NewRepositoryService(repositoryRepo, teamRepo, cfg.App.ReviewerGroups)
+	authConfig := &service.AuthConfig{
+		BootstrapKey:  cfg.Auth.BootstrapKey,
+		UserClaim:     cfg.Auth.OIDC.UserClaim,
+		TenantClaim:   cfg.Auth.OIDC.TenantClaim,
+		RoleClaim:     cfg.Auth.OIDC.RoleClaim,
+		DefaultTenant: cfg.Tenancy.DefaultTenant,
+		DefaultRole:   entity.Role(cfg.Auth.OIDC.DefaultRole),
+	}
+	if cfg.Auth.OIDC.Issuer != "" {
+		verifier, err := oidc.NewVerifier(oidc.Config{
+			Issuer:   cfg.Auth.OIDC.Issuer,
+			JWKSURL:  cfg.Auth.OIDC.JWKSURL,
+			Audience: cfg.Auth.OIDC.Audience,
+			Refresh:  cfg.Auth.OIDC.JWKSRefresh,
+			Leeway:   cfg.Auth.OIDC.Leeway,
+		})
+		if err != nil {
+			log.Error("invalid oidc config", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		authConfig.Tokens = verifier
+	}
+	authService, err := service.NewAuthService(apiKeyRepo, userRepo, teamRepo, authConfig)
+	if err != nil {
+		log.Error("failed to init auth service", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	vcsSecrets, err := tenantVCSSecrets(cfg.VCS.Tenants)
+	if err != nil {
+		log.Error("invalid vcs secrets", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		var store ratelimit.Store
+		switch cfg.RateLimit.Store {
+		case "", "memory":
+			store = ratelimit.NewMemoryStore()
+		case "postgres":
+			store = postgres.NewRateLimitStore(db)
+		default:
+			log.Error("unknown rate limit store", slog.String("store", cfg.RateLimit.Store))
+			os.Exit(1)
+		}
+
+		routes := make(map[string]ratelimit.Limit, len(cfg.RateLimit.Routes))
+		for route, limit := range cfg.RateLimit.Routes {
+			routes[route] = routeLimit(limit)
+		}
+		limiter = ratelimit.NewLimiter(store, routeLimit(cfg.RateLimit.Default), routes)
+	}
+
+	idempotencyTTL := cfg.Idempotency.TTL
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = 24 * time.Hour
+	}
 
 	log.Info("initializing HTTP server...")
-	router := server.NewRouter(userService, teamService, prService, log)
-	handler := router.SetupRoutes()
+	router := server.NewRouter(userService, teamService, prService, statsService, metricsService, webhookService, vcsService, vcsSecrets, codeownersService, repositoryService, authService, cfg.Auth.Required, limiter, idempotencyRepo, idempotencyTTL, cfg.Tenancy.DefaultTenant, log)
+	// cancel a request's queries once the server gives up on writing its
+	// response
+	handler := middleware.Deadline(cfg.Server.WriteTimeout)(router.SetupRoutes())
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
@@ -79,6 +172,21 @@ func main() {
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
+	dispatcher := webhook.NewDispatcher(webhookRepo, webhook.Config{
+		PollInterval: cfg.Webhooks.PollInterval,
+		BatchSize:    cfg.Webhooks.BatchSize,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		BaseBackoff:  cfg.Webhooks.BaseBackoff,
+		MaxBackoff:   cfg.Webhooks.MaxBackoff,
+		Timeout:      cfg.Webhooks.Timeout,
+	}, log)
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		dispatcher.Run(dispatchCtx)
+	}()
+
 	log.Info("starting HTTP server", slog.Int("port", cfg.Server.Port))
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -102,9 +210,50 @@ func main() {
 		log.Error("failed to shutdown server", slog.String("error", err.Error()))
 	}
 
+	stopDispatch()
+	<-dispatchDone
+
 	log.Info("server stopped")
 }
 
+func mergePolicy(policy config.MergePolicy) service.MergePolicy {
+	return service.MergePolicy{
+		MinApprovals:            policy.MinApprovals,
+		BlockOnChangesRequested: policy.BlockOnChangesRequested,
+		RequiredGroup:           policy.RequiredGroup,
+	}
+}
+
+// tenantVCSSecrets checks that no secret is shared between tenants, as a
+// delivery's secret decides the tenant it works on.
+func tenantVCSSecrets(tenants map[string]config.VCSSecrets) (map[string]handlers.VCSSecrets, error) {
+	secrets := make(map[string]handlers.VCSSecrets, len(tenants))
+	owners := make(map[string]string)
+	for tenant, s := range tenants {
+		for provider, secret := range map[string]string{service.ProviderGitHub: s.GitHubSecret, service.ProviderGitLab: s.GitLabToken} {
+			if secret == "" {
+				continue
+			}
+			if owner, ok := owners[provider+":"+secret]; ok {
+				return nil, fmt.Errorf("tenants %s and %s share a %s webhook secret", owner, tenant, provider)
+			}
+			owners[provider+":"+secret] = tenant
+		}
+		secrets[tenant] = handlers.VCSSecrets{
+			GitHub: s.GitHubSecret,
+			GitLab: s.GitLabToken,
+		}
+	}
+	return secrets, nil
+}
+
+func routeLimit(limit config.RouteLimit) ratelimit.Limit {
+	return ratelimit.Limit{
+		Rate:  limit.Rate,
+		Burst: limit.Burst,
+	}
+}
+
 func SetupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 	switch env {
@@ -124,7 +273,7 @@ func waitForDB(cfg *config.Config, log *slog.Logger) {
     if os.Getenv("ENV") != "docker" {
         return
     }
-    
+
     dsn := fmt.Sprintf(
         "host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
         cfg.Database.Host,
@@ -134,7 +283,7 @@ func waitForDB(cfg *config.Config, log *slog.Logger) {
         cfg.Database.Name,
         cfg.Database.SSLMode,
     )
-    
+
     for i := 0; i < 30; i++ {
         db, err := sql.Open("pgx", dsn)
         if err != nil {
@@ -142,7 +291,7 @@ func waitForDB(cfg *config.Config, log *slog.Logger) {
             time.Sleep(1 * time.Second)
             continue
         }
-        
+
         if err := db.Ping(); err == nil {
             db.Close()
             log.Info("Database is ready!")
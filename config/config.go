@@ -27,19 +27,89 @@ type Config struct {
 		MaxOpenConns    int           `yaml:"maxOpenConns"`
 		MaxIdleConns    int           `yaml:"maxIdleConns"`
 		ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+		QueryTimeout    time.Duration `yaml:"queryTimeout"`
 	} `yaml:"database"`
 
+	Webhooks struct {
+		PollInterval time.Duration `yaml:"pollInterval"`
+		BatchSize    int           `yaml:"batchSize"`
+		MaxAttempts  int           `yaml:"maxAttempts"`
+		BaseBackoff  time.Duration `yaml:"baseBackoff"`
+		MaxBackoff   time.Duration `yaml:"maxBackoff"`
+		Timeout      time.Duration `yaml:"timeout"`
+	} `yaml:"webhooks"`
+
+	VCS struct {
+		Tenants map[string]VCSSecrets `yaml:"tenants"`
+	} `yaml:"vcs"`
+
+	Auth struct {
+		Required     bool   `yaml:"required"`
+		BootstrapKey string `yaml:"bootstrapKey"`
+		OIDC         struct {
+			Issuer      string        `yaml:"issuer"`
+			JWKSURL     string        `yaml:"jwksURL"`
+			Audience    string        `yaml:"audience"`
+			UserClaim   string        `yaml:"userClaim"`
+			TenantClaim string        `yaml:"tenantClaim"`
+			RoleClaim   string        `yaml:"roleClaim"`
+			DefaultRole string        `yaml:"defaultRole"`
+			JWKSRefresh time.Duration `yaml:"jwksRefresh"`
+			Leeway      time.Duration `yaml:"leeway"`
+		} `yaml:"oidc"`
+	} `yaml:"auth"`
+
+	RateLimit struct {
+		Enabled bool                  `yaml:"enabled"`
+		Store   string                `yaml:"store"`
+		Default RouteLimit            `yaml:"default"`
+		Routes  map[string]RouteLimit `yaml:"routes"`
+	} `yaml:"rateLimit"`
+
+	Idempotency struct {
+		TTL time.Duration `yaml:"ttl"`
+	} `yaml:"idempotency"`
+
+	Tenancy struct {
+		DefaultTenant string `yaml:"defaultTenant"`
+	} `yaml:"tenancy"`
+
 	Logging struct {
 		Level  string `yaml:"level"`
 		Format string `yaml:"format"`
 	} `yaml:"logging"`
 
 	App struct {
-		ReviewerCount int `yaml:"reviewerCount"`
-		RandomSeed    int `yaml:"randomSeed"`
+		ReviewerCount     int                    `yaml:"reviewerCount"`
+		RandomSeed        int                    `yaml:"randomSeed"`
+		ReviewerStrategy  string                 `yaml:"reviewerStrategy"`
+		TeamStrategies    map[string]string      `yaml:"teamStrategies"`
+		ReviewerWeights   map[string]int         `yaml:"reviewerWeights"`
+		CapacityOverflow  string                 `yaml:"capacityOverflow"`
+		MergePolicy       MergePolicy            `yaml:"mergePolicy"`
+		TeamMergePolicies map[string]MergePolicy `yaml:"teamMergePolicies"`
+		ReviewerGroups    map[string][]string    `yaml:"reviewerGroups"`
 	} `yaml:"app"`
 }
 
+// RouteLimit allows Rate requests per second with bursts of up to Burst.
+type RouteLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// VCSSecrets authenticate one tenant's provider webhooks.
+type VCSSecrets struct {
+	GitHubSecret string `yaml:"githubSecret"`
+	GitLabToken  string `yaml:"gitlabToken"`
+}
+
+type MergePolicy struct {
+	MinApprovals            int    `yaml:"minApprovals"`
+	BlockOnChangesRequested bool   `yaml:"blockOnChangesRequested"`
+	RequiredGroup           string `yaml:"requiredGroup"`
+}
+
 func Load(path string) *Config {
 	cfg := &Config{}
 
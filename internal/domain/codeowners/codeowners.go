@@ -0,0 +1,121 @@
+// Package codeowners parses CODEOWNERS files with GitHub's semantics: each
+// line is a gitignore-style pattern followed by owners, and the last line
+// matching a path decides its owners.
+package codeowners
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule is one pattern line. A rule without owners marks paths as unowned.
+type Rule struct {
+	Line    int      `json:"line"`
+	Pattern string   `json:"pattern"`
+	Owners  []string `json:"owners"`
+
+	re *regexp.Regexp
+}
+
+type File struct {
+	Rules []*Rule
+}
+
+// Parse reads a CODEOWNERS file. Blank lines and comments are skipped.
+func Parse(content string) (*File, error) {
+	file := &File{}
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if idx := strings.Index(line, " #"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+
+		fields := strings.Fields(line)
+		pattern := strings.ReplaceAll(fields[0], `\#`, "#")
+		if strings.HasPrefix(pattern, "!") {
+			return nil, fmt.Errorf("line %d: negated patterns are not supported", i+1)
+		}
+
+		re, err := compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		file.Rules = append(file.Rules, &Rule{
+			Line:    i + 1,
+			Pattern: pattern,
+			Owners:  fields[1:],
+			re:      re,
+		})
+	}
+
+	return file, nil
+}
+
+// Match returns the last rule matching path, or nil.
+func (f *File) Match(path string) *Rule {
+	path = strings.TrimPrefix(path, "/")
+	for i := len(f.Rules) - 1; i >= 0; i-- {
+		if f.Rules[i].re.MatchString(path) {
+			return f.Rules[i]
+		}
+	}
+	return nil
+}
+
+// compile turns a gitignore-style pattern into a regexp over slash-separated
+// paths relative to the repository root.
+//
+// A pattern with a slash anywhere but at the end is anchored to the root,
+// otherwise it matches at any depth. A pattern naming a directory also
+// matches everything below it, except when its last segment is a wildcard:
+// "docs/*" covers docs/a.md but not docs/sub/b.md.
+func compile(pattern string) (*regexp.Regexp, error) {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	trimmed := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(trimmed, "/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+	if trimmed == "" {
+		return nil, fmt.Errorf("empty pattern %q", pattern)
+	}
+
+	var b strings.Builder
+	if anchored || strings.HasPrefix(trimmed, "**") {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(trimmed); i++ {
+		switch c := trimmed[i]; {
+		case strings.HasPrefix(trimmed[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(trimmed[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	last := trimmed[strings.LastIndex(trimmed, "/")+1:]
+	switch {
+	case dirOnly:
+		b.WriteString("/.*$")
+	case strings.ContainsAny(last, "*?"):
+		b.WriteString("$")
+	default:
+		b.WriteString("(?:/.*)?$")
+	}
+
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,108 @@
+package codeowners
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		path    string
+		want    []string
+		wantNil bool
+	}{
+		{name: "last match wins", file: "* @all\n*.go @gophers", path: "cmd/main.go", want: []string{"@gophers"}},
+		{name: "earlier rule where the last does not match", file: "* @all\n*.go @gophers", path: "README.md", want: []string{"@all"}},
+		{name: "later broad rule overrides", file: "/api/ @api\n* @all", path: "api/handler.go", want: []string{"@all"}},
+		{name: "rule without owners unowns", file: "* @all\n/vendor/", path: "vendor/lib/x.go", want: []string{}},
+		{name: "no match", file: "/docs/ @docs", path: "src/main.go", wantNil: true},
+
+		{name: "unanchored name at the root", file: "Makefile @build", path: "Makefile", want: []string{"@build"}},
+		{name: "unanchored name at any depth", file: "Makefile @build", path: "tools/gen/Makefile", want: []string{"@build"}},
+		{name: "unanchored extension at any depth", file: "*.sql @dba", path: "cmd/migrations/001.sql", want: []string{"@dba"}},
+		{name: "leading slash anchors", file: "/build @build", path: "build/Dockerfile", want: []string{"@build"}},
+		{name: "leading slash does not match deeper", file: "/build @build", path: "cmd/build/Dockerfile", wantNil: true},
+		{name: "inner slash anchors", file: "internal/domain @core", path: "internal/domain/entity/user.go", want: []string{"@core"}},
+		{name: "inner slash does not match deeper", file: "internal/domain @core", path: "pkg/internal/domain/x.go", wantNil: true},
+		{name: "leading slash of the path is ignored", file: "/docs/ @docs", path: "/docs/README.md", want: []string{"@docs"}},
+
+		{name: "dir/ covers everything below", file: "docs/ @docs", path: "docs/api/openapi.yaml", want: []string{"@docs"}},
+		{name: "dir/ matches at any depth", file: "docs/ @docs", path: "pkg/docs/a.md", want: []string{"@docs"}},
+		{name: "dir/ does not match a file of that name", file: "docs/ @docs", path: "docs", wantNil: true},
+		{name: "dir/* covers direct children", file: "/docs/* @docs", path: "docs/a.md", want: []string{"@docs"}},
+		{name: "dir/* does not cover grandchildren", file: "/docs/* @docs", path: "docs/api/openapi.yaml", wantNil: true},
+		{name: "dir/** covers grandchildren", file: "/docs/** @docs", path: "docs/api/v1/openapi.yaml", want: []string{"@docs"}},
+		{name: "**/name matches at the root", file: "**/logs @ops", path: "logs/app.log", want: []string{"@ops"}},
+		{name: "**/name matches at any depth", file: "**/logs @ops", path: "a/b/logs/app.log", want: []string{"@ops"}},
+		{name: "a/**/b matches directly", file: "/a/**/b @ab", path: "a/b", want: []string{"@ab"}},
+		{name: "a/**/b matches in between", file: "/a/**/b @ab", path: "a/x/y/b/c.go", want: []string{"@ab"}},
+		{name: "star stays within a segment", file: "/cmd/*.go @cmd", path: "cmd/sub/main.go", wantNil: true},
+		{name: "question mark is one character", file: "/v?.txt @v", path: "v1.txt", want: []string{"@v"}},
+		{name: "question mark is not a slash", file: "/v?.txt @v", path: "v/.txt", wantNil: true},
+		{name: "dots are literal", file: "*.go @gophers", path: "main_go", wantNil: true},
+
+		{name: "escaped hash is part of the pattern", file: `/notes/\#1.md @notes`, path: "notes/#1.md", want: []string{"@notes"}},
+		{name: "trailing comment is stripped", file: "/api/ @api # the api team", path: "api/x.go", want: []string{"@api"}},
+		{name: "comment lines are skipped", file: "# /api/ @nobody\n/api/ @api", path: "api/x.go", want: []string{"@api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse(tt.file)
+			if err != nil {
+				t.Fatalf("Parse(): %v", err)
+			}
+
+			rule := file.Match(tt.path)
+			if tt.wantNil {
+				if rule != nil {
+					t.Fatalf("Match(%q) = line %d %q, want no match", tt.path, rule.Line, rule.Pattern)
+				}
+				return
+			}
+			if rule == nil {
+				t.Fatalf("Match(%q) = nil, want %v", tt.path, tt.want)
+			}
+			if !slices.Equal(rule.Owners, tt.want) {
+				t.Fatalf("Match(%q) owners = %v, want %v", tt.path, rule.Owners, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	file, err := Parse("# owners\n\n* @all\n  /docs/ @docs @writers  \n")
+	if err != nil {
+		t.Fatalf("Parse(): %v", err)
+	}
+	if len(file.Rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(file.Rules))
+	}
+	rule := file.Rules[1]
+	if rule.Line != 4 || rule.Pattern != "/docs/" || !slices.Equal(rule.Owners, []string{"@docs", "@writers"}) {
+		t.Fatalf("rule = %+v", rule)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "negation", file: "* @all\n!/docs/ @docs", wantErr: "line 2: negated patterns are not supported"},
+		{name: "root only", file: "/ @all", wantErr: "line 1: empty pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.file)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
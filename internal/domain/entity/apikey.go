@@ -0,0 +1,66 @@
+package entity
+
+import "time"
+
+// Role is what an API key is allowed to do. Roles are ordered: every role
+// may do whatever the roles below it may.
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleTeamLead Role = "team-lead"
+	RoleMember   Role = "member"
+	RoleReadOnly Role = "read-only"
+)
+
+var roleRank = map[Role]int{
+	RoleReadOnly: 1,
+	RoleMember:   2,
+	RoleTeamLead: 3,
+	RoleAdmin:    4,
+}
+
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// Allows reports whether r is at least min.
+func (r Role) Allows(min Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[min]
+}
+
+// APIKey is an issued key. Only a hash of the key is stored; the key itself
+// is reported once, when it is issued. TeamName scopes team leads, UserID is
+// the actor recorded for the key's requests.
+type APIKey struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Role      Role       `json:"role"`
+	UserID    string     `json:"user_id,omitempty"`
+	TeamName  string     `json:"team_name,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Principal is who a request is made by. An empty TenantID means the
+// principal is not bound to a tenant, like the bootstrap key, and the
+// request picks one.
+type Principal struct {
+	TenantID string
+	KeyID    int64
+	Role     Role
+	UserID   string
+	TeamName string
+}
+
+// CanManageTeam reports whether p may change the members of teamName.
+func (p *Principal) CanManageTeam(teamName string) bool {
+	switch p.Role {
+	case RoleAdmin:
+		return true
+	case RoleTeamLead:
+		return p.TeamName == teamName
+	default:
+		return false
+	}
+}
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+type AssignmentEventType string
+
+const (
+	EventAssigned      AssignmentEventType = "ASSIGNED"
+	EventUnassigned    AssignmentEventType = "UNASSIGNED"
+	EventReassigned    AssignmentEventType = "REASSIGNED"
+	EventMerged        AssignmentEventType = "MERGED"
+	EventStatusChanged AssignmentEventType = "STATUS_CHANGED"
+)
+
+// Change says who made a change to a PR and why. It is recorded on every
+// assignment event the change produces; Actor is empty for changes the
+// service makes on its own.
+type Change struct {
+	Actor  string
+	Reason string
+}
+
+// AssignmentEvent is one entry of a PR's append-only history.
+type AssignmentEvent struct {
+	ID            int64               `json:"id"`
+	PullRequestID string              `json:"pull_request_id"`
+	Type          AssignmentEventType `json:"event_type"`
+	Actor         string              `json:"actor,omitempty"`
+	Reason        string              `json:"reason"`
+	OldReviewerID string              `json:"old_reviewer_id,omitempty"`
+	NewReviewerID string              `json:"new_reviewer_id,omitempty"`
+	OldStatus     PRStatus            `json:"old_status,omitempty"`
+	NewStatus     PRStatus            `json:"new_status,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+}
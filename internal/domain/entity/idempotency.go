@@ -0,0 +1,11 @@
+package entity
+
+// IdempotentRequest is a request sent with an Idempotency-Key and the
+// response it got. A zero StatusCode means it is still being processed.
+type IdempotentRequest struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Header      map[string][]string
+	Body        []byte
+}
@@ -5,16 +5,47 @@ import "time"
 type PRStatus string
 
 const (
+	StatusDraft  PRStatus = "DRAFT"
 	StatusOpen   PRStatus = "OPEN"
 	StatusMerged PRStatus = "MERGED"
+	StatusClosed PRStatus = "CLOSED"
 )
 
+type ReviewState string
+
+const (
+	ReviewPending          ReviewState = "PENDING"
+	ReviewApproved         ReviewState = "APPROVED"
+	ReviewChangesRequested ReviewState = "CHANGES_REQUESTED"
+	ReviewCommented        ReviewState = "COMMENTED"
+)
+
+type Review struct {
+	ReviewerID string      `json:"reviewer_id"`
+	State      ReviewState `json:"state"`
+	AssignedAt *time.Time  `json:"assignedAt,omitempty"`
+	ReviewedAt *time.Time  `json:"reviewedAt,omitempty"`
+}
+
+// PullRequest is a PR under review. ChangedFiles are matched against the
+// CODEOWNERS of RepositoryID, and ReviewerSources explains, per reviewer,
+// why they were picked. Version grows with every change, so writers can
+// tell whether the PR changed since they read it.
 type PullRequest struct {
-	PullRequestID     string     `json:"pull_request_id"`
-	PullRequestName   string     `json:"pull_request_name"`
-	AuthorID          string     `json:"author_id"`
-	Status            PRStatus   `json:"status"`
-	AssignedReviewers []string   `json:"assigned_reviewers"`
-	CreatedAt         *time.Time `json:"createdAt,omitempty"`
-	MergedAt          *time.Time `json:"mergedAt,omitempty"`
+	PullRequestID     string            `json:"pull_request_id"`
+	PullRequestName   string            `json:"pull_request_name"`
+	AuthorID          string            `json:"author_id"`
+	RepositoryID      string            `json:"repository_id,omitempty"`
+	ChangedFiles      []string          `json:"changed_files,omitempty"`
+	Status            PRStatus          `json:"status"`
+	AssignedReviewers []string          `json:"assigned_reviewers"`
+	FallbackReviewers []string          `json:"fallback_reviewers,omitempty"`
+	ReviewerSources   map[string]string `json:"reviewer_sources,omitempty"`
+	Reviews           []Review          `json:"reviews,omitempty"`
+	CreatedAt         *time.Time        `json:"createdAt,omitempty"`
+	MergedAt          *time.Time        `json:"mergedAt,omitempty"`
+	ClosedAt          *time.Time        `json:"closedAt,omitempty"`
+	MergedBy          string            `json:"merged_by,omitempty"`
+	ForceMerged       bool              `json:"force_merged,omitempty"`
+	Version           int64             `json:"version"`
 }
@@ -0,0 +1,19 @@
+package entity
+
+// Repository is a codebase PRs belong to. Its settings are overrides: an
+// unset one falls back to the author's team and then to the global config.
+type Repository struct {
+	RepositoryID  string `json:"repository_id"`
+	ReviewerCount *int   `json:"reviewer_count,omitempty"`
+	Strategy      string `json:"strategy,omitempty"`
+	// EligibleTeams are the teams reviewers are drawn from, in priority
+	// order, in place of the author's team and its fallbacks.
+	EligibleTeams []string     `json:"eligible_teams,omitempty"`
+	MergePolicy   *MergePolicy `json:"merge_policy,omitempty"`
+}
+
+type MergePolicy struct {
+	MinApprovals            int    `json:"min_approvals"`
+	BlockOnChangesRequested bool   `json:"block_on_changes_requested"`
+	RequiredGroup           string `json:"required_group,omitempty"`
+}
@@ -0,0 +1,59 @@
+package entity
+
+import "time"
+
+// StatsFilter narrows reviewer statistics to a time window and a team. Zero
+// values leave the corresponding side unbounded.
+type StatsFilter struct {
+	From     *time.Time
+	To       *time.Time
+	TeamName string
+}
+
+// ReviewerStats counts a user's review assignments. TotalAssignments
+// includes the ones later reassigned away.
+type ReviewerStats struct {
+	UserID           string `json:"user_id"`
+	Username         string `json:"username"`
+	TeamName         string `json:"team_name"`
+	TotalAssignments int    `json:"total_assignments"`
+	OpenAssignments  int    `json:"open_assignments"`
+	MergedReviewed   int    `json:"merged_reviewed"`
+	ReassignedAway   int    `json:"reassigned_away"`
+}
+
+// TeamStats sums ReviewerStats over a team's members.
+type TeamStats struct {
+	TeamName         string `json:"team_name"`
+	Members          int    `json:"members"`
+	TotalAssignments int    `json:"total_assignments"`
+	OpenAssignments  int    `json:"open_assignments"`
+	MergedReviewed   int    `json:"merged_reviewed"`
+	ReassignedAway   int    `json:"reassigned_away"`
+}
+
+// MetricGroup is the dimension latency percentiles are grouped by.
+type MetricGroup string
+
+const (
+	GroupTeam   MetricGroup = "team"
+	GroupAuthor MetricGroup = "author"
+)
+
+// Latency summarises a duration distribution for one group, in seconds.
+type Latency struct {
+	Key        string  `json:"key"`
+	Count      int     `json:"count"`
+	P50Seconds float64 `json:"p50_seconds"`
+	P90Seconds float64 `json:"p90_seconds"`
+	P99Seconds float64 `json:"p99_seconds"`
+}
+
+// ReviewMetrics are the review SLA percentiles for a time window. Time to
+// merge runs from PR creation, time to first review from a reviewer's
+// assignment to their first decision, grouped by the reviewer's team.
+type ReviewMetrics struct {
+	TimeToMergeByTeam       []*Latency `json:"time_to_merge_by_team"`
+	TimeToMergeByAuthor     []*Latency `json:"time_to_merge_by_author"`
+	TimeToFirstReviewByTeam []*Latency `json:"time_to_first_review_by_team"`
+}
@@ -1,6 +1,7 @@
 package entity
 
 type Team struct {
-	TeamName string `json:"team_name"`
-	Members  []User `json:"members"`
+	TeamName      string   `json:"team_name"`
+	Members       []User   `json:"members"`
+	FallbackTeams []string `json:"fallback_teams,omitempty"`
 }
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types.
+const (
+	WebhookPRCreated        = "pull_request.created"
+	WebhookPRMerged         = "pull_request.merged"
+	WebhookPRStatusChanged  = "pull_request.status_changed"
+	WebhookReviewersChanged = "pull_request.reviewers_changed"
+)
+
+var WebhookEventTypes = []string{
+	WebhookPRCreated,
+	WebhookPRMerged,
+	WebhookPRStatusChanged,
+	WebhookReviewersChanged,
+}
+
+// WebhookPayload is the JSON body POSTed to webhooks. PullRequest is the PR
+// after the change; bulk reviewer changes only carry PullRequestID.
+type WebhookPayload struct {
+	Event         string            `json:"event"`
+	OccurredAt    time.Time         `json:"occurred_at"`
+	PullRequestID string            `json:"pull_request_id"`
+	PullRequest   *PullRequest      `json:"pull_request,omitempty"`
+	Changes       []AssignmentEvent `json:"changes,omitempty"`
+}
+
+// Webhook is a registered receiver. An empty EventTypes subscribes to every
+// event. Secret signs the payloads and is only reported on registration.
+type Webhook struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliveryDelivered DeliveryStatus = "DELIVERED"
+	DeliveryDead      DeliveryStatus = "DEAD"
+)
+
+// Delivery is one outbox event on its way to one webhook.
+type Delivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     int64           `json:"webhook_id"`
+	OutboxID      int64           `json:"outbox_id"`
+	URL           string          `json:"url"`
+	Secret        string          `json:"-"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+)
+
+// Error kinds. Every domain error unwraps to one of them, so callers can
+// classify failures with errors.Is without knowing the concrete code.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrForbidden    = errors.New("forbidden")
+	// ErrPreconditionFailed is a client precondition, like If-Match, that
+	// does not hold.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// Error codes reported to API clients.
+const (
+	CodeNotFound           = "NOT_FOUND"
+	CodeBadRequest         = "BAD_REQUEST"
+	CodeTeamExists         = "TEAM_EXISTS"
+	CodePRExists           = "PR_EXISTS"
+	CodePRMerged           = "PR_MERGED"
+	CodePRNotOpen          = "PR_NOT_OPEN"
+	CodeNotAssigned        = "NOT_ASSIGNED"
+	CodeNoCandidate        = "NO_CANDIDATE"
+	CodeNoCapacity         = "NO_CAPACITY"
+	CodeInvalidTransition  = "INVALID_TRANSITION"
+	CodeMergeBlocked       = "MERGE_BLOCKED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeRequestInProgress  = "REQUEST_IN_PROGRESS"
+	CodeConflict           = "CONFLICT"
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+)
+
+// Error is a domain failure with a client-facing code and message.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+func NotFound(format string, args ...any) *Error {
+	return &Error{Kind: ErrNotFound, Code: CodeNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(code, format string, args ...any) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func Invalid(code, format string, args ...any) *Error {
+	return &Error{Kind: ErrInvalidInput, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func Forbidden(format string, args ...any) *Error {
+	return &Error{Kind: ErrForbidden, Code: CodeForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+func PreconditionFailed(format string, args ...any) *Error {
+	return &Error{Kind: ErrPreconditionFailed, Code: CodePreconditionFailed, Message: fmt.Sprintf(format, args...)}
+}
+
+// TransitionError is returned when a PR is asked to make a move the state
+// machine does not allow.
+type TransitionError struct {
+	PullRequestID string
+	From          entity.PRStatus
+	To            entity.PRStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot move pr %s from %s to %s", e.PullRequestID, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrConflict
+}
+
+func (e *TransitionError) ErrorCode() string {
+	return CodeInvalidTransition
+}
+
+// MergeBlockedError is returned when a PR does not satisfy its merge policy.
+type MergeBlockedError struct {
+	PullRequestID string
+	Unmet         []string
+}
+
+func (e *MergeBlockedError) Error() string {
+	return fmt.Sprintf("cannot merge pr %s: %s", e.PullRequestID, strings.Join(e.Unmet, "; "))
+}
+
+func (e *MergeBlockedError) Unwrap() error {
+	return ErrConflict
+}
+
+func (e *MergeBlockedError) ErrorCode() string {
+	return CodeMergeBlocked
+}
+
+func (e *MergeBlockedError) ErrorDetails() any {
+	return map[string][]string{"unmet_conditions": e.Unmet}
+}
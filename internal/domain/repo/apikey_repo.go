@@ -0,0 +1,17 @@
+package repo
+
+import (
+    "context"
+    "github.com/shmul/avito-task/internal/domain/entity"
+)
+
+// APIKeyRepository stores API keys by the hex SHA-256 of the key. Keys are
+// issued and revoked per tenant, but looked up across tenants since the key
+// is what decides the tenant of a request.
+type APIKeyRepository interface {
+    ForTenant(tenantID string) APIKeyRepository
+    Create(ctx context.Context, key *entity.APIKey, keyHash string) error
+    // GetByHash returns the live key with keyHash together with its tenant.
+    GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, string, error)
+    Revoke(ctx context.Context, keyID int64) (*entity.APIKey, error)
+}
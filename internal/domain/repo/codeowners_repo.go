@@ -0,0 +1,11 @@
+package repo
+
+import "context"
+
+type CodeownersRepository interface {
+    ForTenant(tenantID string) CodeownersRepository
+    // Get returns the CODEOWNERS content of a repository, or "" when none
+    // was uploaded.
+    Get(ctx context.Context, repositoryID string) (string, error)
+    Set(ctx context.Context, repositoryID, content string) error
+}
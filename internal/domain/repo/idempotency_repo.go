@@ -0,0 +1,21 @@
+package repo
+
+import (
+    "context"
+    "time"
+    "github.com/shmul/avito-task/internal/domain/entity"
+)
+
+// IdempotencyRepository remembers the responses of requests made with an
+// Idempotency-Key until their TTL runs out.
+type IdempotencyRepository interface {
+    ForTenant(tenantID string) IdempotencyRepository
+    // Begin claims key for a request hashing to requestHash. It returns nil
+    // when the key was free, or expired, and the request holding it
+    // otherwise.
+    Begin(ctx context.Context, key, requestHash string, ttl time.Duration) (*entity.IdempotentRequest, error)
+    Complete(ctx context.Context, key string, statusCode int, header map[string][]string, body []byte) error
+    // Release frees a claimed key without a response, so the request can be
+    // retried.
+    Release(ctx context.Context, key string) error
+}
@@ -1,11 +1,38 @@
 package repo
 
-import "github.com/shmul/avito-task/internal/domain/entity"
+import (
+    "context"
+    "github.com/shmul/avito-task/internal/domain/entity"
+)
 
 type PRRepository interface {
-    Create(pr *entity.PullRequest) error
-    GetByID(prID string) (*entity.PullRequest, error)
-    Update(pr *entity.PullRequest) error
-    GetByReviewer(userID string) ([]*entity.PullRequest, error)
-    Exists(prID string) (bool, error)
+    ForTenant(tenantID string) PRRepository
+    Create(ctx context.Context, pr *entity.PullRequest, change entity.Change) error
+    GetByID(ctx context.Context, prID string) (*entity.PullRequest, error)
+    Update(ctx context.Context, pr *entity.PullRequest, change entity.Change) error
+    GetByReviewer(ctx context.Context, userID string) ([]*entity.PullRequest, error)
+    GetOpenByReviewers(ctx context.Context, reviewerIDs []string) ([]*entity.PullRequest, error)
+    ReplaceReviewers(ctx context.Context, changes []ReviewerChange, change entity.Change) error
+    Exists(ctx context.Context, prID string) (bool, error)
+    GetOpenReviewCounts(ctx context.Context, teamName string) (map[string]int, error)
+    QueueForAssignment(ctx context.Context, prID string) error
+    GetQueuedForAssignment(ctx context.Context) ([]string, error)
+    RemoveFromAssignmentQueue(ctx context.Context, prID string) error
+    SubmitReview(ctx context.Context, prID, reviewerID string, state entity.ReviewState, version int64) error
+    GetHistory(ctx context.Context, prID string) ([]*entity.AssignmentEvent, error)
+    GetReviewerStats(ctx context.Context, filter entity.StatsFilter) ([]*entity.ReviewerStats, error)
+    GetTeamStats(ctx context.Context, filter entity.StatsFilter) ([]*entity.TeamStats, error)
+    GetTimeToMerge(ctx context.Context, filter entity.StatsFilter, group entity.MetricGroup) ([]*entity.Latency, error)
+    GetTimeToFirstReview(ctx context.Context, filter entity.StatsFilter) ([]*entity.Latency, error)
+}
+
+// ReviewerChange swaps one reviewer of a PR for another. An empty
+// NewReviewerID drops the old reviewer without replacement. Version is the
+// version of the PR the change was decided on.
+type ReviewerChange struct {
+    PullRequestID string
+    OldReviewerID string
+    NewReviewerID string
+    Fallback      bool
+    Version       int64
 }
\ No newline at end of file
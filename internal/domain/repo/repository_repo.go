@@ -0,0 +1,13 @@
+package repo
+
+import (
+    "context"
+    "github.com/shmul/avito-task/internal/domain/entity"
+)
+
+type RepositoryRepository interface {
+    ForTenant(tenantID string) RepositoryRepository
+    // Save creates the repository or replaces all of its settings.
+    Save(ctx context.Context, repository *entity.Repository) error
+    GetByID(ctx context.Context, repositoryID string) (*entity.Repository, error)
+}
@@ -1,9 +1,15 @@
 package repo
 
-import "github.com/shmul/avito-task/internal/domain/entity"
+import (
+    "context"
+    "github.com/shmul/avito-task/internal/domain/entity"
+)
 
 type TeamRepository interface {
-    Create(team *entity.Team) error
-    GetByName(teamName string) (*entity.Team, error)
-    Exists(teamName string) (bool, error)
+    ForTenant(tenantID string) TeamRepository
+    Create(ctx context.Context, team *entity.Team) error
+    GetByName(ctx context.Context, teamName string) (*entity.Team, error)
+    Exists(ctx context.Context, teamName string) (bool, error)
+    GetFallbacks(ctx context.Context, teamName string) ([]string, error)
+    SetFallbacks(ctx context.Context, teamName string, fallbackTeams []string) error
 }
\ No newline at end of file
@@ -0,0 +1,17 @@
+package repo
+
+import "context"
+
+// Repositories groups repositories that share one transaction.
+type Repositories struct {
+    PRs   PRRepository
+    Users UserRepository
+    Teams TeamRepository
+}
+
+// Transactor runs fn with repositories bound to a single transaction. The
+// transaction commits when fn returns nil and rolls back otherwise.
+type Transactor interface {
+    ForTenant(tenantID string) Transactor
+    WithinTx(ctx context.Context, fn func(r Repositories) error) error
+}
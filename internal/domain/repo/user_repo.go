@@ -1,12 +1,19 @@
 package repo
 
-import "github.com/shmul/avito-task/internal/domain/entity"
+import (
+    "context"
+    "github.com/shmul/avito-task/internal/domain/entity"
+)
 
 type UserRepository interface {
-    CreateOrUpdate(user *entity.User) error
-    GetByID(userID string) (*entity.User, error)
-    SetActive(userID string, isActive bool) (*entity.User, error)
-    GetActiveUsersByTeam(teamName string) ([]*entity.User, error)
-    GetByTeam(teamName string) ([]*entity.User, error)
-    Exists(userID string) (bool, error)
+    ForTenant(tenantID string) UserRepository
+    CreateOrUpdate(ctx context.Context, user *entity.User) error
+    GetByID(ctx context.Context, userID string) (*entity.User, error)
+    SetActive(ctx context.Context, userID string, isActive bool) (*entity.User, error)
+    SetTeamActive(ctx context.Context, teamName string, userIDs []string, isActive bool) ([]*entity.User, error)
+    GetActiveUsersByTeam(ctx context.Context, teamName string) ([]*entity.User, error)
+    GetByTeam(ctx context.Context, teamName string) ([]*entity.User, error)
+    Exists(ctx context.Context, userID string) (bool, error)
+    GetByVCSLogin(ctx context.Context, provider, login string) (*entity.User, error)
+    LinkVCSAccount(ctx context.Context, provider, login, userID string) error
 }
\ No newline at end of file
@@ -0,0 +1,30 @@
+package repo
+
+import (
+    "context"
+    "time"
+    "github.com/shmul/avito-task/internal/domain/entity"
+)
+
+// WebhookRepository registers webhooks per tenant, while delivery works
+// across all tenants: every outbox event is only fanned out to webhooks of
+// its own tenant.
+type WebhookRepository interface {
+    ForTenant(tenantID string) WebhookRepository
+    Create(ctx context.Context, webhook *entity.Webhook) error
+    GetFailedDeliveries(ctx context.Context, limit int) ([]*entity.Delivery, error)
+    // FanOut turns up to limit undispatched outbox events into pending
+    // deliveries for every subscribed webhook and reports how many events it
+    // took.
+    FanOut(ctx context.Context, limit int) (int, error)
+    // ClaimDue leases up to limit due deliveries for lease, counting the
+    // attempt, so concurrent dispatchers never send the same one.
+    ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entity.Delivery, error)
+    // MarkDelivered and MarkFailed record the outcome of the claim that
+    // counted attempts. Once the delivery has been claimed again they fail
+    // with a CONFLICT error and leave it to the newer claim.
+    MarkDelivered(ctx context.Context, deliveryID int64, attempts int) error
+    // MarkFailed records a failed attempt. A nil retryAt gives up on the
+    // delivery.
+    MarkFailed(ctx context.Context, deliveryID int64, attempts int, lastError string, retryAt *time.Time) error
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+// apiKeyPrefix marks API keys so they can be told apart from other bearer
+// tokens and spotted by secret scanners.
+const apiKeyPrefix = "prk_"
+
+// ErrInvalidCredentials is returned by Authenticate for unknown, revoked
+// and malformed API keys and for tokens failing verification alike.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// TokenVerifier checks the signature and registered claims of a JWT and
+// returns its claims. Tokens failing the checks get an error wrapping
+// ErrInvalidCredentials; other errors, like an unreachable issuer, are
+// failures to check the token at all.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (map[string]any, error)
+}
+
+type AuthConfig struct {
+	// BootstrapKey authenticates as an admin of whichever tenant the
+	// request names, so the first real keys can be issued. It is never
+	// stored.
+	BootstrapKey string
+	// Tokens verifies JWT bearer tokens; without one only API keys are
+	// accepted.
+	Tokens TokenVerifier
+	// UserClaim holds the UserID of a token's user. TenantClaim and
+	// RoleClaim are optional, tokens without them get DefaultTenant and
+	// DefaultRole.
+	UserClaim     string
+	TenantClaim   string
+	RoleClaim     string
+	DefaultTenant string
+	DefaultRole   entity.Role
+}
+
+type AuthService struct {
+	apiKeyRepo    repo.APIKeyRepository
+	userRepo      repo.UserRepository
+	teamRepo      repo.TeamRepository
+	config        *AuthConfig
+	bootstrapHash []byte
+}
+
+func NewAuthService(apiKeyRepo repo.APIKeyRepository, userRepo repo.UserRepository, teamRepo repo.TeamRepository, config *AuthConfig) (*AuthService, error) {
+	if config.Tokens != nil {
+		if config.UserClaim == "" {
+			config.UserClaim = "sub"
+		}
+		if config.DefaultRole == "" {
+			config.DefaultRole = entity.RoleMember
+		}
+		if !config.DefaultRole.Valid() {
+			return nil, fmt.Errorf("unknown default role: %s", config.DefaultRole)
+		}
+	}
+
+	s := &AuthService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+		teamRepo:   teamRepo,
+		config:     config,
+	}
+	if config.BootstrapKey != "" {
+		sum := sha256.Sum256([]byte(config.BootstrapKey))
+		s.bootstrapHash = sum[:]
+	}
+	return s, nil
+}
+
+// ForTenant returns a copy of s that issues and revokes tenantID's keys.
+// Authenticate is not affected, keys are always looked up across tenants.
+func (s *AuthService) ForTenant(tenantID string) *AuthService {
+	scoped := *s
+	scoped.apiKeyRepo = s.apiKeyRepo.ForTenant(tenantID)
+	scoped.userRepo = s.userRepo.ForTenant(tenantID)
+	scoped.teamRepo = s.teamRepo.ForTenant(tenantID)
+	return &scoped
+}
+
+// Authenticate resolves a bearer credential, an API key or a JWT, to the
+// principal it was issued to.
+func (s *AuthService) Authenticate(ctx context.Context, rawKey string) (*entity.Principal, error) {
+	if s.config.Tokens != nil && strings.Count(rawKey, ".") == 2 {
+		return s.authenticateToken(ctx, rawKey)
+	}
+
+	sum := sha256.Sum256([]byte(rawKey))
+	if s.bootstrapHash != nil && subtle.ConstantTimeCompare(sum[:], s.bootstrapHash) == 1 {
+		return &entity.Principal{Role: entity.RoleAdmin}, nil
+	}
+
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+
+	key, tenantID, err := s.apiKeyRepo.GetByHash(ctx, hex.EncodeToString(sum[:]))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	return &entity.Principal{
+		TenantID: tenantID,
+		KeyID:    key.ID,
+		Role:     key.Role,
+		UserID:   key.UserID,
+		TeamName: key.TeamName,
+	}, nil
+}
+
+// authenticateToken maps a verified token onto the user named by its user
+// claim, who has to exist in the token's tenant. Team leads lead their own
+// team.
+func (s *AuthService) authenticateToken(ctx context.Context, token string) (*entity.Principal, error) {
+	claims, err := s.config.Tokens.Verify(ctx, token)
+	if errors.Is(err, ErrInvalidCredentials) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify token: %w", err)
+	}
+
+	tenantID := s.config.DefaultTenant
+	if s.config.TenantClaim != "" {
+		if claim, _ := claims[s.config.TenantClaim].(string); claim != "" {
+			tenantID = claim
+		}
+	}
+
+	role := s.config.DefaultRole
+	if s.config.RoleClaim != "" {
+		if claim, _ := claims[s.config.RoleClaim].(string); claim != "" {
+			role = entity.Role(claim)
+		}
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %s", ErrInvalidCredentials, role)
+	}
+
+	userID, _ := claims[s.config.UserClaim].(string)
+	if userID == "" || tenantID == "" {
+		return nil, fmt.Errorf("%w: token has no %s", ErrInvalidCredentials, s.config.UserClaim)
+	}
+
+	user, err := s.userRepo.ForTenant(tenantID).GetByID(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown user %s", ErrInvalidCredentials, userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	return &entity.Principal{
+		TenantID: tenantID,
+		Role:     role,
+		UserID:   user.UserID,
+		TeamName: user.TeamName,
+	}, nil
+}
+
+// IssueKey creates a key and returns it together with the raw key, which is
+// not recoverable afterwards. Team-lead keys need the team they lead; a
+// team lead given only a user leads that user's team.
+func (s *AuthService) IssueKey(ctx context.Context, key *entity.APIKey) (string, error) {
+	if !key.Role.Valid() {
+		return "", domain.Invalid(domain.CodeBadRequest, "unknown role: %s", key.Role)
+	}
+
+	if key.UserID != "" {
+		user, err := s.userRepo.GetByID(ctx, key.UserID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get user: %w", err)
+		}
+		if key.Role == entity.RoleTeamLead && key.TeamName == "" {
+			key.TeamName = user.TeamName
+		}
+	}
+
+	if key.TeamName != "" {
+		exists, err := s.teamRepo.Exists(ctx, key.TeamName)
+		if err != nil {
+			return "", fmt.Errorf("failed to check team existence: %w", err)
+		}
+		if !exists {
+			return "", domain.NotFound("team %s not found", key.TeamName)
+		}
+	} else if key.Role == entity.RoleTeamLead {
+		return "", domain.Invalid(domain.CodeBadRequest, "team-lead keys need a team_name or user_id")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	rawKey := apiKeyPrefix + hex.EncodeToString(buf)
+
+	sum := sha256.Sum256([]byte(rawKey))
+	if err := s.apiKeyRepo.Create(ctx, key, hex.EncodeToString(sum[:])); err != nil {
+		return "", fmt.Errorf("failed to issue api key: %w", err)
+	}
+
+	return rawKey, nil
+}
+
+func (s *AuthService) RevokeKey(ctx context.Context, keyID int64) (*entity.APIKey, error) {
+	key, err := s.apiKeyRepo.Revoke(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return key, nil
+}
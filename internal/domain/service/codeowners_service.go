@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/codeowners"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type CodeownersService struct {
+	codeownersRepo repo.CodeownersRepository
+}
+
+func NewCodeownersService(codeownersRepo repo.CodeownersRepository) *CodeownersService {
+	return &CodeownersService{codeownersRepo: codeownersRepo}
+}
+
+// ForTenant returns a copy of s that works on tenantID's data only.
+func (s *CodeownersService) ForTenant(tenantID string) *CodeownersService {
+	return &CodeownersService{codeownersRepo: s.codeownersRepo.ForTenant(tenantID)}
+}
+
+// Upload replaces the CODEOWNERS file of repositoryID. The file is parsed
+// first so a broken one never reaches reviewer selection.
+func (s *CodeownersService) Upload(ctx context.Context, repositoryID, content string) (*codeowners.File, error) {
+	if repositoryID == "" {
+		return nil, domain.Invalid(domain.CodeBadRequest, "repository_id is required")
+	}
+
+	file, err := codeowners.Parse(content)
+	if err != nil {
+		return nil, domain.Invalid(domain.CodeBadRequest, "invalid CODEOWNERS: %s", err)
+	}
+
+	if err := s.codeownersRepo.Set(ctx, repositoryID, content); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *CodeownersService) Get(ctx context.Context, repositoryID string) (*codeowners.File, error) {
+	content, err := s.codeownersRepo.Get(ctx, repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	if content == "" {
+		return nil, domain.NotFound("no CODEOWNERS for repository %s", repositoryID)
+	}
+
+	file, err := codeowners.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse codeowners: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+)
+
+// MergePolicy lists the conditions an OPEN PR has to meet before MergePR
+// lets it through. The zero value allows every merge.
+type MergePolicy struct {
+	MinApprovals            int
+	BlockOnChangesRequested bool
+	// RequiredGroup names a ReviewerGroups entry; at least one of its
+	// members has to approve.
+	RequiredGroup string
+}
+
+// unmet returns a description of every condition pr does not meet.
+func (p MergePolicy) unmet(pr *entity.PullRequest, groups map[string][]string) []string {
+	var approvals int
+	var changesRequested, groupApproved []string
+	for _, review := range pr.Reviews {
+		switch review.State {
+		case entity.ReviewApproved:
+			approvals++
+			if slices.Contains(groups[p.RequiredGroup], review.ReviewerID) {
+				groupApproved = append(groupApproved, review.ReviewerID)
+			}
+		case entity.ReviewChangesRequested:
+			changesRequested = append(changesRequested, review.ReviewerID)
+		}
+	}
+
+	var unmet []string
+	if approvals < p.MinApprovals {
+		unmet = append(unmet, fmt.Sprintf("%d approvals required, got %d", p.MinApprovals, approvals))
+	}
+	if p.BlockOnChangesRequested {
+		for _, reviewerID := range changesRequested {
+			unmet = append(unmet, fmt.Sprintf("changes requested by %s", reviewerID))
+		}
+	}
+	if p.RequiredGroup != "" && len(groupApproved) == 0 {
+		unmet = append(unmet, fmt.Sprintf("approval from group %s required", p.RequiredGroup))
+	}
+
+	return unmet
+}
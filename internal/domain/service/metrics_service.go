@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+// MetricsService computes review latency percentiles for SLA tracking.
+type MetricsService struct {
+	prRepo repo.PRRepository
+}
+
+func NewMetricsService(prRepo repo.PRRepository) *MetricsService {
+	return &MetricsService{prRepo: prRepo}
+}
+
+// ForTenant returns a copy of s that reports on tenantID's data only.
+func (s *MetricsService) ForTenant(tenantID string) *MetricsService {
+	return &MetricsService{prRepo: s.prRepo.ForTenant(tenantID)}
+}
+
+func (s *MetricsService) GetReviewMetrics(ctx context.Context, filter entity.StatsFilter) (*entity.ReviewMetrics, error) {
+	if err := checkWindow(filter); err != nil {
+		return nil, err
+	}
+
+	byTeam, err := s.prRepo.GetTimeToMerge(ctx, filter, entity.GroupTeam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time to merge by team: %w", err)
+	}
+
+	byAuthor, err := s.prRepo.GetTimeToMerge(ctx, filter, entity.GroupAuthor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time to merge by author: %w", err)
+	}
+
+	firstReview, err := s.prRepo.GetTimeToFirstReview(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time to first review: %w", err)
+	}
+
+	return &entity.ReviewMetrics{
+		TimeToMergeByTeam:       byTeam,
+		TimeToMergeByAuthor:     byAuthor,
+		TimeToFirstReviewByTeam: firstReview,
+	}, nil
+}
@@ -1,19 +1,35 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math/rand"
+	"slices"
+	"strings"
 	"time"
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/codeowners"
 	"github.com/shmul/avito-task/internal/domain/entity"
 	"github.com/shmul/avito-task/internal/domain/repo"
 )
 
 type PRService struct {
-	prRepo   repo.PRRepository
-	userRepo repo.UserRepository
-	teamRepo repo.TeamRepository
-	config   *PRServiceConfig
-	rng      *rand.Rand
+	prRepo         repo.PRRepository
+	userRepo       repo.UserRepository
+	teamRepo       repo.TeamRepository
+	codeownersRepo repo.CodeownersRepository
+	repositoryRepo repo.RepositoryRepository
+	tx             repo.Transactor
+	config         *PRServiceConfig
+	log            *slog.Logger
+	rng            *rand.Rand
+	selectors      *selectorSet
+	tenant         string
+	// ifMatch lists the versions the caller expects a PR it changes to be
+	// at; nil accepts any version.
+	ifMatch []int64
 }
 
 //для тестов
@@ -26,8 +42,36 @@ type PRService struct {
 //
 
 type PRServiceConfig struct {
-	ReviewerCount int
-	RandomSeed    int64
+	ReviewerCount     int
+	RandomSeed        int64
+	Strategy          string
+	TeamStrategies    map[string]string
+	ReviewerWeights   map[string]int
+	CapacityOverflow  string
+	MergePolicy       MergePolicy
+	TeamMergePolicies map[string]MergePolicy
+	ReviewerGroups    map[string][]string
+}
+
+// What to do when reviewer capacity limits leave a PR with fewer reviewers
+// than ReviewerCount.
+const (
+	OverflowAssignFewer = "assign-fewer"
+	OverflowFail        = "fail"
+	OverflowQueue       = "queue"
+)
+
+// CreatePRInput describes a new PR. RepositoryID and ChangedFiles are
+// optional; together they let the repository's CODEOWNERS pick owners.
+// ActorID is who creates the PR, recorded in its history.
+type CreatePRInput struct {
+	PullRequestID   string
+	PullRequestName string
+	AuthorID        string
+	Draft           bool
+	RepositoryID    string
+	ChangedFiles    []string
+	ActorID         string
 }
 
 type ReassignResult struct {
@@ -35,7 +79,14 @@ type ReassignResult struct {
 	ReplacedBy string
 }
 
-func NewPRService(prRepo repo.PRRepository, userRepo repo.UserRepository, teamRepo repo.TeamRepository, config *PRServiceConfig) *PRService {
+// Reassignment is one PR a deactivated reviewer was replaced on.
+type Reassignment struct {
+	PullRequestID string
+	OldReviewerID string
+	ReplacedBy    string
+}
+
+func NewPRService(prRepo repo.PRRepository, userRepo repo.UserRepository, teamRepo repo.TeamRepository, codeownersRepo repo.CodeownersRepository, repositoryRepo repo.RepositoryRepository, tx repo.Transactor, config *PRServiceConfig, log *slog.Logger) (*PRService, error) {
 	var seed int64
 	if config.RandomSeed == 0 {
 		seed = time.Now().UnixNano()
@@ -43,166 +94,1068 @@ func NewPRService(prRepo repo.PRRepository, userRepo repo.UserRepository, teamRe
 		seed = config.RandomSeed
 	}
 
-	src := rand.NewSource(seed)
-	rng := rand.New(src)
+	switch config.CapacityOverflow {
+	case "":
+		config.CapacityOverflow = OverflowAssignFewer
+	case OverflowAssignFewer, OverflowFail, OverflowQueue:
+	default:
+		return nil, fmt.Errorf("unknown capacity overflow mode: %s", config.CapacityOverflow)
+	}
 
-	return &PRService{
-		prRepo:   prRepo,
-		userRepo: userRepo,
-		teamRepo: teamRepo,
-		config:   config,
-		rng:      rng,
+	// selectors of every tenant draw from rng concurrently
+	rng := rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+
+	// every known strategy gets a selector since repositories can pick
+	// theirs at any time
+	names := append([]string{config.Strategy}, strategies...)
+	for _, strategy := range config.TeamStrategies {
+		if !slices.Contains(names, strategy) {
+			names = append(names, strategy)
+		}
+	}
+	selectors, err := newSelectorSet(rng, prRepo, config.ReviewerWeights, names)
+	if err != nil {
+		return nil, err
 	}
+
+	return &PRService{
+		prRepo:         prRepo,
+		userRepo:       userRepo,
+		teamRepo:       teamRepo,
+		codeownersRepo: codeownersRepo,
+		repositoryRepo: repositoryRepo,
+		tx:             tx,
+		config:         config,
+		log:            log,
+		rng:            rng,
+		selectors:      selectors,
+	}, nil
 }
 
-func (s *PRService) CreatePR(prID, prName, authorID string) (*entity.PullRequest, error) {
-	exists, err := s.prRepo.Exists(prID)
+func (s *PRService) CreatePR(ctx context.Context, input CreatePRInput) (*entity.PullRequest, error) {
+	exists, err := s.prRepo.Exists(ctx, input.PullRequestID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check pr existence: %w", err)
 	}
 	if exists {
-		return nil, fmt.Errorf("pr already exists: %s", prID)
+		return nil, domain.Conflict(domain.CodePRExists, "PR %s already exists", input.PullRequestID)
 	}
 
-	author, err := s.userRepo.GetByID(authorID)
+	author, err := s.userRepo.GetByID(ctx, input.AuthorID)
 	if err != nil {
-		return nil, fmt.Errorf("author not found: %s", authorID)
+		return nil, fmt.Errorf("failed to get author: %w", err)
 	}
 
-	teamUsers, err := s.userRepo.GetActiveUsersByTeam(author.TeamName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get team users: %w", err)
+	pr := &entity.PullRequest{
+		PullRequestID:     input.PullRequestID,
+		PullRequestName:   input.PullRequestName,
+		AuthorID:          input.AuthorID,
+		RepositoryID:      input.RepositoryID,
+		ChangedFiles:      input.ChangedFiles,
+		Status:            entity.StatusDraft,
+		AssignedReviewers: []string{},
+		ReviewerSources:   map[string]string{},
 	}
 
-	var candidates []*entity.User
-	for _, user := range teamUsers {
-		if user.UserID != authorID {
-			candidates = append(candidates, user)
+	// drafts get reviewers only once they are marked ready
+	var queue bool
+	if !input.Draft {
+		pr.Status = entity.StatusOpen
+		settings, err := s.settingsFor(ctx, pr.RepositoryID, author.TeamName)
+		if err != nil {
+			return nil, err
+		}
+		if queue, err = s.staff(ctx, pr, settings); err != nil {
+			return nil, err
 		}
 	}
 
-	reviewers := s.selectRandomReviewers(candidates, s.config.ReviewerCount)
+	err = s.save(ctx, pr, queue, func(prs repo.PRRepository) error {
+		if err := prs.Create(ctx, pr, entity.Change{Actor: input.ActorID, Reason: "created"}); err != nil {
+			return fmt.Errorf("failed to create pr: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	pr := &entity.PullRequest{
-		PullRequestID:     prID,
-		PullRequestName:   prName,
-		AuthorID:          authorID,
-		Status:            entity.StatusOpen,
-		AssignedReviewers: reviewers,
+	return pr, nil
+}
+
+// MergePR merges an OPEN PR that satisfies its merge policy on behalf of
+// actor. With force an admin actor tied to a user can merge regardless of
+// the policy; the override is recorded on the PR.
+func (s *PRService) MergePR(ctx context.Context, prID string, force bool, actor *entity.Principal) (*entity.PullRequest, error) {
+	var actorID string
+	if actor != nil {
+		actorID = actor.UserID
 	}
 
-	if err := s.prRepo.Create(pr); err != nil {
-		return nil, fmt.Errorf("failed to create pr: %w", err)
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+	if err := s.checkVersion(pr); err != nil {
+		return nil, err
 	}
 
-	return pr, nil
+	if pr.Status == entity.StatusMerged {
+		return pr, nil
+	}
+
+	// the override is audited, so it needs an admin who is a known user
+	if force && (actorID == "" || !actor.Role.Allows(entity.RoleAdmin)) {
+		return nil, domain.Forbidden("force merge requires an admin user")
+	}
+
+	author, err := s.userRepo.GetByID(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get author: %w", err)
+	}
+
+	settings, err := s.settingsFor(ctx, pr.RepositoryID, author.TeamName)
+	if err != nil {
+		return nil, err
+	}
+
+	unmet := settings.mergePolicy.unmet(pr, s.config.ReviewerGroups)
+	if len(unmet) > 0 && !force {
+		return nil, &domain.MergeBlockedError{PullRequestID: pr.PullRequestID, Unmet: unmet}
+	}
+
+	reason := "merged"
+	if len(unmet) > 0 {
+		reason = "force-merged"
+	}
+	return s.merge(ctx, pr, actorID, len(unmet) > 0, reason)
 }
 
-func (s *PRService) MergePR(prID string) (*entity.PullRequest, error) {
-	pr, err := s.prRepo.GetByID(prID)
+// MergeExternal records a merge that already happened in the VCS. The merge
+// policy is not enforced; a PR that did not meet it is flagged ForceMerged.
+func (s *PRService) MergeExternal(ctx context.Context, prID, actorID string) (*entity.PullRequest, error) {
+	pr, err := s.prRepo.GetByID(ctx, prID)
 	if err != nil {
-		return nil, fmt.Errorf("pr not found: %s", prID)
+		return nil, fmt.Errorf("failed to get pr: %w", err)
 	}
 
 	if pr.Status == entity.StatusMerged {
 		return pr, nil
 	}
 
-	now := time.Now()
-	pr.Status = entity.StatusMerged
-	pr.MergedAt = &now
+	author, err := s.userRepo.GetByID(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get author: %w", err)
+	}
+
+	settings, err := s.settingsFor(ctx, pr.RepositoryID, author.TeamName)
+	if err != nil {
+		return nil, err
+	}
+
+	unmet := settings.mergePolicy.unmet(pr, s.config.ReviewerGroups)
+	return s.merge(ctx, pr, actorID, len(unmet) > 0, "merged upstream")
+}
 
-	if err := s.prRepo.Update(pr); err != nil {
+func (s *PRService) merge(ctx context.Context, pr *entity.PullRequest, actorID string, forced bool, reason string) (*entity.PullRequest, error) {
+	if err := transition(pr, entity.StatusMerged); err != nil {
+		return nil, err
+	}
+	pr.MergedBy = actorID
+	pr.ForceMerged = forced
+
+	if err := s.prRepo.Update(ctx, pr, entity.Change{Actor: actorID, Reason: reason}); err != nil {
 		return nil, fmt.Errorf("failed to merge pr: %w", err)
 	}
 
+	// the merge freed review capacity; whatever is still unassigned stays
+	// queued until the next merge
+	s.retryQueued(ctx, pr.PullRequestID, actorID)
+
+	return pr, nil
+}
+
+func (s *PRService) ClosePR(ctx context.Context, prID, actorID string) (*entity.PullRequest, error) {
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+	if err := s.checkVersion(pr); err != nil {
+		return nil, err
+	}
+
+	if err := transition(pr, entity.StatusClosed); err != nil {
+		return nil, err
+	}
+
+	if err := s.prRepo.Update(ctx, pr, entity.Change{Actor: actorID, Reason: "closed"}); err != nil {
+		return nil, fmt.Errorf("failed to close pr: %w", err)
+	}
+
+	if err := s.prRepo.RemoveFromAssignmentQueue(ctx, pr.PullRequestID); err != nil {
+		return nil, fmt.Errorf("failed to dequeue pr: %w", err)
+	}
+	s.retryQueued(ctx, pr.PullRequestID, actorID)
+
 	return pr, nil
 }
 
-func (s *PRService) ReassignReviewer(prID, oldReviewerID string) (*ReassignResult, error) {
-	pr, err := s.prRepo.GetByID(prID)
+// ReopenPR moves a CLOSED PR back to OPEN, topping up reviewers if it has
+// fewer than ReviewerCount.
+func (s *PRService) ReopenPR(ctx context.Context, prID, actorID string) (*entity.PullRequest, error) {
+	return s.open(ctx, prID, actorID, entity.StatusClosed, "reopened")
+}
+
+// MarkReady moves a DRAFT PR to OPEN and assigns its reviewers.
+func (s *PRService) MarkReady(ctx context.Context, prID, actorID string) (*entity.PullRequest, error) {
+	return s.open(ctx, prID, actorID, entity.StatusDraft, "marked ready")
+}
+
+// MarkDraft moves an OPEN PR back to DRAFT. Its reviewers stay assigned for
+// when it is marked ready again, but drafts do not count towards their
+// load, so queued PRs get a go at the freed capacity.
+func (s *PRService) MarkDraft(ctx context.Context, prID, actorID string) (*entity.PullRequest, error) {
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+	if err := s.checkVersion(pr); err != nil {
+		return nil, err
+	}
+
+	if err := transition(pr, entity.StatusDraft); err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(r repo.Repositories) error {
+		if err := r.PRs.Update(ctx, pr, entity.Change{Actor: actorID, Reason: "marked draft"}); err != nil {
+			return fmt.Errorf("failed to update pr: %w", err)
+		}
+		if err := r.PRs.RemoveFromAssignmentQueue(ctx, pr.PullRequestID); err != nil {
+			return fmt.Errorf("failed to dequeue pr: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("pr not found: %s", prID)
+		return nil, err
+	}
+	s.retryQueued(ctx, pr.PullRequestID, actorID)
+
+	return pr, nil
+}
+
+func (s *PRService) open(ctx context.Context, prID, actorID string, from entity.PRStatus, reason string) (*entity.PullRequest, error) {
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+	if err := s.checkVersion(pr); err != nil {
+		return nil, err
+	}
+
+	if pr.Status != from {
+		return nil, &domain.TransitionError{PullRequestID: pr.PullRequestID, From: pr.Status, To: entity.StatusOpen}
+	}
+	if err := transition(pr, entity.StatusOpen); err != nil {
+		return nil, err
+	}
+
+	author, err := s.userRepo.GetByID(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get author: %w", err)
+	}
+
+	settings, err := s.settingsFor(ctx, pr.RepositoryID, author.TeamName)
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := s.staff(ctx, pr, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.update(ctx, pr, queue, entity.Change{Actor: actorID, Reason: reason}); err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+func (s *PRService) ReassignReviewer(ctx context.Context, prID, oldReviewerID, actorID string) (*ReassignResult, error) {
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+	if err := s.checkVersion(pr); err != nil {
+		return nil, err
 	}
 
 	if pr.Status == entity.StatusMerged {
-		return nil, fmt.Errorf("cannot reassign on merged pr")
+		return nil, domain.Conflict(domain.CodePRMerged, "cannot reassign on merged PR")
+	}
+	if pr.Status != entity.StatusOpen {
+		return nil, domain.Conflict(domain.CodePRNotOpen, "cannot reassign on %s PR", strings.ToLower(string(pr.Status)))
 	}
 
 	if !s.contains(pr.AssignedReviewers, oldReviewerID) {
-		return nil, fmt.Errorf("reviewer is not assigned to this pr")
+		return nil, domain.Conflict(domain.CodeNotAssigned, "reviewer is not assigned to this PR")
+	}
+
+	replacedBy, err := s.replaceReviewer(ctx, pr, oldReviewerID, true, entity.Change{Actor: actorID, Reason: "reassigned"})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReassignResult{
+		PR:         pr,
+		ReplacedBy: replacedBy,
+	}
+
+	return result, nil
+}
+
+// replaceReviewer swaps oldReviewerID on pr for another member of their team
+// or its fallbacks, or of the repository's eligible teams, and saves pr. Unless strict, a missing candidate is not an
+// error: the old reviewer is dropped and the returned replacement is empty.
+func (s *PRService) replaceReviewer(ctx context.Context, pr *entity.PullRequest, oldReviewerID string, strict bool, change entity.Change) (string, error) {
+	old, err := s.userRepo.GetByID(ctx, oldReviewerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get reviewer: %w", err)
 	}
 
-	old, err := s.userRepo.GetByID(oldReviewerID)
+	settings, err := s.settingsFor(ctx, pr.RepositoryID, old.TeamName)
 	if err != nil {
-		return nil, fmt.Errorf("reviewer not found: %s", oldReviewerID)
+		return "", err
 	}
 
-	teamUsers, err := s.userRepo.GetActiveUsersByTeam(old.TeamName)
+	exclude := append([]string{pr.AuthorID}, pr.AssignedReviewers...)
+	assigned, err := s.assignReviewers(ctx, settings, exclude, 1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get team users: %w", err)
+		return "", fmt.Errorf("failed to select reviewer: %w", err)
+	}
+	if strict && len(assigned.reviewers) == 0 && !assigned.short {
+		return "", domain.Conflict(domain.CodeNoCandidate, "no active replacement candidate in team")
+	}
+	if strict && assigned.short && s.config.CapacityOverflow == OverflowFail {
+		return "", domain.Conflict(domain.CodeNoCapacity, "no reviewer capacity in team")
 	}
 
-	var candidates []*entity.User
-	for _, user := range teamUsers {
-		if user.UserID != pr.AuthorID &&
-			!s.contains(pr.AssignedReviewers, user.UserID) &&
-			user.UserID != oldReviewerID {
-			candidates = append(candidates, user)
+	// otherwise the old reviewer is dropped without replacement
+	var replacedBy string
+	reviewers := make([]string, 0, len(pr.AssignedReviewers))
+	for _, reviewer := range pr.AssignedReviewers {
+		if reviewer != oldReviewerID {
+			reviewers = append(reviewers, reviewer)
+		} else if len(assigned.reviewers) > 0 {
+			replacedBy = assigned.reviewers[0]
+			reviewers = append(reviewers, replacedBy)
 		}
 	}
+	pr.AssignedReviewers = reviewers
 
-	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no active replacement candidate in team")
+	var fallbacks []string
+	for _, reviewer := range pr.FallbackReviewers {
+		if reviewer != oldReviewerID {
+			fallbacks = append(fallbacks, reviewer)
+		}
+	}
+	pr.FallbackReviewers = append(fallbacks, assigned.fallback...)
+	delete(pr.ReviewerSources, oldReviewerID)
+	if replacedBy != "" {
+		setSource(pr, replacedBy, assigned.sources[replacedBy])
 	}
 
-	new := candidates[s.rng.Intn(len(candidates))]
+	if err := s.update(ctx, pr, assigned.short && s.config.CapacityOverflow == OverflowQueue, change); err != nil {
+		return "", err
+	}
 
-	for i, reviewer := range pr.AssignedReviewers {
-		if reviewer == oldReviewerID {
-			pr.AssignedReviewers[i] = new.UserID
-			break
+	return replacedBy, nil
+}
+
+// releaseReviewer takes userID off every OPEN PR they review.
+func (s *PRService) releaseReviewer(ctx context.Context, userID, actorID string) ([]Reassignment, []string, error) {
+	prs, err := s.prRepo.GetByReviewer(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get PRs by reviewer: %w", err)
+	}
+
+	reassigned := []Reassignment{}
+	unassignable := []string{}
+	for _, pr := range prs {
+		if pr.Status != entity.StatusOpen {
+			continue
+		}
+
+		replacedBy, err := s.replaceReviewer(ctx, pr, userID, false, entity.Change{Actor: actorID, Reason: "reviewer deactivated"})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if replacedBy == "" {
+			unassignable = append(unassignable, pr.PullRequestID)
+		} else {
+			reassigned = append(reassigned, Reassignment{PullRequestID: pr.PullRequestID, OldReviewerID: userID, ReplacedBy: replacedBy})
 		}
 	}
 
-	if err := s.prRepo.Update(pr); err != nil {
-		return nil, fmt.Errorf("failed to update pr: %w", err)
+	return reassigned, unassignable, nil
+}
+
+// releaseReviewers moves every OPEN review held by userIDs, all members of
+// teamName, to the least loaded active member of teamName or, failing that,
+// of its fallback teams; PRs of a repository with eligible teams draw from
+// those instead. Unlike releaseReviewer it skips the team selectors and
+// reads and writes in bulk, so a whole team can be released at once.
+func (s *PRService) releaseReviewers(ctx context.Context, teamName string, userIDs []string, actorID string) ([]Reassignment, []string, error) {
+	reassigned := []Reassignment{}
+	unassignable := []string{}
+
+	prs, err := s.prRepo.GetOpenByReviewers(ctx, userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(prs) == 0 {
+		return reassigned, unassignable, nil
 	}
 
-	result := &ReassignResult{
-		PR:         pr,
-		ReplacedBy: new.UserID,
+	// every team is read once, however many PRs draw from it
+	load := make(map[string]int)
+	members := make(map[string][]*entity.User)
+	settings := make(map[string]*reviewSettings)
+	poolsFor := func(pr *entity.PullRequest) ([][]*entity.User, error) {
+		if _, ok := settings[pr.RepositoryID]; !ok {
+			resolved, err := s.settingsFor(ctx, pr.RepositoryID, teamName)
+			if err != nil {
+				return nil, err
+			}
+			settings[pr.RepositoryID] = resolved
+		}
+
+		var pools [][]*entity.User
+		for _, team := range settings[pr.RepositoryID].teams {
+			if _, ok := members[team]; !ok {
+				users, err := s.userRepo.GetActiveUsersByTeam(ctx, team)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get team users: %w", err)
+				}
+				counts, err := s.prRepo.GetOpenReviewCounts(ctx, team)
+				if err != nil {
+					return nil, err
+				}
+				for userID, count := range counts {
+					load[userID] = count
+				}
+				members[team] = users
+			}
+			pools = append(pools, members[team])
+		}
+		return pools, nil
 	}
 
-	return result, nil
+	var changes []repo.ReviewerChange
+	for _, pr := range prs {
+		pools, err := poolsFor(pr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var dropped bool
+		for _, reviewerID := range pr.AssignedReviewers {
+			if !s.contains(userIDs, reviewerID) {
+				continue
+			}
+
+			change := repo.ReviewerChange{PullRequestID: pr.PullRequestID, OldReviewerID: reviewerID, Version: pr.Version}
+			exclude := append([]string{pr.AuthorID}, pr.AssignedReviewers...)
+			if user, pool := s.leastLoaded(pools, load, exclude); user != nil {
+				change.NewReviewerID = user.UserID
+				change.Fallback = pool > 0
+				load[user.UserID]++
+				// ranging over the original slice, so the new reviewer is
+				// only seen by the exclusions
+				pr.AssignedReviewers = append(pr.AssignedReviewers, user.UserID)
+				reassigned = append(reassigned, Reassignment{PullRequestID: pr.PullRequestID, OldReviewerID: reviewerID, ReplacedBy: user.UserID})
+			} else {
+				dropped = true
+			}
+			changes = append(changes, change)
+		}
+		if dropped {
+			unassignable = append(unassignable, pr.PullRequestID)
+		}
+	}
+
+	if err := s.prRepo.ReplaceReviewers(ctx, changes, entity.Change{Actor: actorID, Reason: "reviewer deactivated"}); err != nil {
+		return nil, nil, fmt.Errorf("failed to replace reviewers: %w", err)
+	}
+
+	if s.config.CapacityOverflow == OverflowQueue {
+		for _, prID := range unassignable {
+			if err := s.prRepo.QueueForAssignment(ctx, prID); err != nil {
+				return nil, nil, fmt.Errorf("failed to queue pr for assignment: %w", err)
+			}
+		}
+	}
+
+	return reassigned, unassignable, nil
 }
 
-func (s *PRService) GetPRsByReviewer(userID string) ([]*entity.PullRequest, error) {
-	prs, err := s.prRepo.GetByReviewer(userID)
+// leastLoaded picks, from the first pool that has one, the member with spare
+// capacity and the fewest open reviews, never anyone in exclude. It also
+// returns the index of that pool.
+func (s *PRService) leastLoaded(pools [][]*entity.User, load map[string]int, exclude []string) (*entity.User, int) {
+	for i, users := range pools {
+		var best *entity.User
+		for _, user := range users {
+			if s.contains(exclude, user.UserID) {
+				continue
+			}
+			if user.MaxOpenReviews != nil && load[user.UserID] >= *user.MaxOpenReviews {
+				continue
+			}
+			if best == nil || load[user.UserID] < load[best.UserID] {
+				best = user
+			}
+		}
+		if best != nil {
+			return best, i
+		}
+	}
+	return nil, -1
+}
+
+// ForTenant returns a copy of s that reads and writes tenantID's data only.
+func (s *PRService) ForTenant(tenantID string) *PRService {
+	scoped := *s
+	scoped.prRepo = s.prRepo.ForTenant(tenantID)
+	scoped.userRepo = s.userRepo.ForTenant(tenantID)
+	scoped.teamRepo = s.teamRepo.ForTenant(tenantID)
+	scoped.codeownersRepo = s.codeownersRepo.ForTenant(tenantID)
+	scoped.repositoryRepo = s.repositoryRepo.ForTenant(tenantID)
+	scoped.tx = s.tx.ForTenant(tenantID)
+	scoped.tenant = tenantID
+	return &scoped
+}
+
+// IfMatch returns a copy of s that changes a PR only while its version is
+// one of versions. A nil versions accepts any version.
+func (s *PRService) IfMatch(versions []int64) *PRService {
+	matched := *s
+	matched.ifMatch = versions
+	return &matched
+}
+
+// checkVersion fails when the caller asked for specific versions of pr and
+// pr is at none of them.
+func (s *PRService) checkVersion(pr *entity.PullRequest) error {
+	if s.ifMatch == nil || slices.Contains(s.ifMatch, pr.Version) {
+		return nil
+	}
+	return domain.PreconditionFailed("PR %s is at version %d", pr.PullRequestID, pr.Version)
+}
+
+// bind returns a copy of s working through r, so its reads and writes join
+// the transaction r belongs to.
+func (s *PRService) bind(r repo.Repositories) *PRService {
+	bound := *s
+	bound.prRepo = r.PRs
+	bound.userRepo = r.Users
+	bound.teamRepo = r.Teams
+	bound.tx = boundTx(r)
+	return &bound
+}
+
+// boundTx is the transaction a bound PRService already works in; WithinTx
+// joins it rather than beginning another.
+type boundTx repo.Repositories
+
+func (t boundTx) ForTenant(string) repo.Transactor {
+	return t
+}
+
+func (t boundTx) WithinTx(_ context.Context, fn func(r repo.Repositories) error) error {
+	return fn(repo.Repositories(t))
+}
+
+// save runs write and, when queue is set, queues pr for assignment in one
+// transaction, so pr is never stored short of reviewers without a queue
+// entry to fill them.
+func (s *PRService) save(ctx context.Context, pr *entity.PullRequest, queue bool, write func(prs repo.PRRepository) error) error {
+	return s.tx.WithinTx(ctx, func(r repo.Repositories) error {
+		if err := write(r.PRs); err != nil {
+			return err
+		}
+		if queue {
+			if err := r.PRs.QueueForAssignment(ctx, pr.PullRequestID); err != nil {
+				return fmt.Errorf("failed to queue pr for assignment: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// update saves pr with change, queueing it for assignment when queue is set.
+func (s *PRService) update(ctx context.Context, pr *entity.PullRequest, queue bool, change entity.Change) error {
+	return s.save(ctx, pr, queue, func(prs repo.PRRepository) error {
+		if err := prs.Update(ctx, pr, change); err != nil {
+			return fmt.Errorf("failed to update pr: %w", err)
+		}
+		return nil
+	})
+}
+
+// ReviewPR records reviewerID's decision on an OPEN PR. A reviewer can
+// review again; the latest decision wins.
+func (s *PRService) ReviewPR(ctx context.Context, prID, reviewerID string, state entity.ReviewState) (*entity.PullRequest, error) {
+	switch state {
+	case entity.ReviewApproved, entity.ReviewChangesRequested, entity.ReviewCommented:
+	default:
+		return nil, domain.Invalid(domain.CodeBadRequest, "invalid review state: %s", state)
+	}
+
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+	if err := s.checkVersion(pr); err != nil {
+		return nil, err
+	}
+
+	if pr.Status != entity.StatusOpen {
+		return nil, domain.Conflict(domain.CodePRNotOpen, "cannot review %s PR", strings.ToLower(string(pr.Status)))
+	}
+
+	if !s.contains(pr.AssignedReviewers, reviewerID) {
+		return nil, domain.Conflict(domain.CodeNotAssigned, "reviewer is not assigned to this PR")
+	}
+
+	if err := s.prRepo.SubmitReview(ctx, prID, reviewerID, state, pr.Version); err != nil {
+		return nil, fmt.Errorf("failed to submit review: %w", err)
+	}
+
+	pr, err = s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+
+	return pr, nil
+}
+
+func (s *PRService) GetPR(ctx context.Context, prID string) (*entity.PullRequest, error) {
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+	return pr, nil
+}
+
+// GetHistory returns the assignment events of a PR, oldest first.
+func (s *PRService) GetHistory(ctx context.Context, prID string) ([]*entity.AssignmentEvent, error) {
+	exists, err := s.prRepo.Exists(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check pr existence: %w", err)
+	}
+	if !exists {
+		return nil, domain.NotFound("PR %s not found", prID)
+	}
+
+	events, err := s.prRepo.GetHistory(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr history: %w", err)
+	}
+	return events, nil
+}
+
+func (s *PRService) GetPRsByReviewer(ctx context.Context, userID string) ([]*entity.PullRequest, error) {
+	prs, err := s.prRepo.GetByReviewer(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get PRs by reviewer: %w", err)
 	}
 	return prs, nil
 }
 
-func (s *PRService) selectRandomReviewers(candidates []*entity.User, maxCount int) []string {
-	if len(candidates) == 0 {
-		return []string{}
+// staff assigns pr's code owners and then tops up reviewers from the teams
+// in settings, applying the capacity overflow mode. It reports whether pr
+// should be queued once it is saved, and does not persist pr itself.
+func (s *PRService) staff(ctx context.Context, pr *entity.PullRequest, settings *reviewSettings) (bool, error) {
+	if err := s.assignOwners(ctx, pr, settings); err != nil {
+		return false, err
 	}
 
-	shuffled := make([]*entity.User, len(candidates))
-	copy(shuffled, candidates)
-	s.rng.Shuffle(len(shuffled), func(i, j int) {
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-	})
+	missing := settings.reviewerCount - len(pr.AssignedReviewers)
+	if missing <= 0 {
+		return false, nil
+	}
+
+	exclude := append([]string{pr.AuthorID}, pr.AssignedReviewers...)
+	assigned, err := s.assignReviewers(ctx, settings, exclude, missing)
+	if err != nil {
+		return false, fmt.Errorf("failed to select reviewers: %w", err)
+	}
+	if assigned.short && s.config.CapacityOverflow == OverflowFail {
+		return false, domain.Conflict(domain.CodeNoCapacity, "no reviewer capacity in team")
+	}
+
+	addReviewers(pr, assigned)
+
+	return assigned.short && s.config.CapacityOverflow == OverflowQueue, nil
+}
+
+// assignOwners adds one owner for every CODEOWNERS rule that decides one of
+// pr's changed files, unless a current reviewer already owns it. Owners are
+// mandatory, so their review capacity is not checked.
+func (s *PRService) assignOwners(ctx context.Context, pr *entity.PullRequest, settings *reviewSettings) error {
+	if pr.RepositoryID == "" || len(pr.ChangedFiles) == 0 {
+		return nil
+	}
+
+	content, err := s.codeownersRepo.Get(ctx, pr.RepositoryID)
+	if err != nil {
+		return err
+	}
+	if content == "" {
+		return nil
+	}
+	file, err := codeowners.Parse(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse codeowners of %s: %w", pr.RepositoryID, err)
+	}
+
+	var rules []*codeowners.Rule
+	for _, path := range pr.ChangedFiles {
+		rule := file.Match(path)
+		if rule != nil && len(rule.Owners) > 0 && !slices.Contains(rules, rule) {
+			rules = append(rules, rule)
+		}
+	}
+	slices.SortFunc(rules, func(a, b *codeowners.Rule) int { return a.Line - b.Line })
+
+	for _, rule := range rules {
+		owners, err := s.resolveOwners(ctx, rule.Owners, pr.AuthorID)
+		if err != nil {
+			return err
+		}
+
+		owned := slices.ContainsFunc(owners, func(user *entity.User) bool {
+			return s.contains(pr.AssignedReviewers, user.UserID)
+		})
+		if owned || len(owners) == 0 {
+			continue
+		}
+
+		team := settings.teams[0]
+		selected, err := s.selectorFor(team, settings.strategy).Select(ctx, team, owners, 1)
+		if err != nil {
+			return err
+		}
+		for _, user := range selected {
+			pr.AssignedReviewers = append(pr.AssignedReviewers, user.UserID)
+			setSource(pr, user.UserID, fmt.Sprintf("CODEOWNERS line %d: %s", rule.Line, rule.Pattern))
+		}
+	}
+
+	return nil
+}
+
+// resolveOwners maps CODEOWNERS owners to active users other than authorID.
+// @user names a user_id or a linked GitHub login, @org/team a team; email
+// owners have no user to map to and are skipped.
+func (s *PRService) resolveOwners(ctx context.Context, owners []string, authorID string) ([]*entity.User, error) {
+	var users []*entity.User
+	add := func(user *entity.User) {
+		if user.IsActive && user.UserID != authorID && !slices.ContainsFunc(users, func(u *entity.User) bool {
+			return u.UserID == user.UserID
+		}) {
+			users = append(users, user)
+		}
+	}
+
+	for _, owner := range owners {
+		name, ok := strings.CutPrefix(owner, "@")
+		if !ok {
+			continue
+		}
+
+		if _, team, ok := strings.Cut(name, "/"); ok {
+			members, err := s.userRepo.GetActiveUsersByTeam(ctx, team)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get team users: %w", err)
+			}
+			for _, member := range members {
+				add(member)
+			}
+			continue
+		}
+
+		user, err := s.userRepo.GetByID(ctx, name)
+		if errors.Is(err, domain.ErrNotFound) {
+			user, err = s.userRepo.GetByVCSLogin(ctx, ProviderGitHub, name)
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve owner %s: %w", owner, err)
+		}
+		add(user)
+	}
+
+	return users, nil
+}
+
+// addReviewers appends the reviewers picked by an assignment to pr.
+func addReviewers(pr *entity.PullRequest, assigned *assignment) {
+	pr.AssignedReviewers = append(pr.AssignedReviewers, assigned.reviewers...)
+	pr.FallbackReviewers = append(pr.FallbackReviewers, assigned.fallback...)
+	for reviewerID, source := range assigned.sources {
+		setSource(pr, reviewerID, source)
+	}
+}
+
+func setSource(pr *entity.PullRequest, reviewerID, source string) {
+	if pr.ReviewerSources == nil {
+		pr.ReviewerSources = make(map[string]string)
+	}
+	pr.ReviewerSources[reviewerID] = source
+}
+
+// retryQueued runs assignQueued once prID has freed review capacity. prID
+// is already saved by then, so a failure is logged rather than failing the
+// request; the PRs stay queued for the next attempt.
+func (s *PRService) retryQueued(ctx context.Context, prID, actorID string) {
+	if err := s.assignQueued(ctx, actorID); err != nil {
+		s.log.Error("failed to assign queued PRs",
+			slog.String("error", err.Error()),
+			slog.String("pull_request_id", prID),
+		)
+	}
+}
+
+// assignQueued tops up reviewers of queued PRs in queue order, removing PRs
+// from the queue once they are fully staffed or no longer open. The
+// assignments are recorded as made by actorID, whose change freed capacity.
+func (s *PRService) assignQueued(ctx context.Context, actorID string) error {
+	prIDs, err := s.prRepo.GetQueuedForAssignment(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, prID := range prIDs {
+		pr, err := s.prRepo.GetByID(ctx, prID)
+		if err != nil {
+			return err
+		}
+
+		if pr.Status != entity.StatusOpen {
+			if err := s.prRepo.RemoveFromAssignmentQueue(ctx, prID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		author, err := s.userRepo.GetByID(ctx, pr.AuthorID)
+		if err != nil {
+			return err
+		}
+		settings, err := s.settingsFor(ctx, pr.RepositoryID, author.TeamName)
+		if err != nil {
+			return err
+		}
+
+		missing := settings.reviewerCount - len(pr.AssignedReviewers)
+		if missing <= 0 {
+			if err := s.prRepo.RemoveFromAssignmentQueue(ctx, prID); err != nil {
+				return err
+			}
+			continue
+		}
 
-	count := min(len(shuffled), maxCount)
-	reviewers := make([]string, count)
-	for i := 0; i < count; i++ {
-		reviewers[i] = shuffled[i].UserID
+		exclude := append([]string{pr.AuthorID}, pr.AssignedReviewers...)
+		assigned, err := s.assignReviewers(ctx, settings, exclude, missing)
+		if err != nil {
+			return err
+		}
+		if len(assigned.reviewers) == 0 {
+			continue
+		}
+
+		addReviewers(pr, assigned)
+		if err := s.prRepo.Update(ctx, pr, entity.Change{Actor: actorID, Reason: "queued assignment"}); err != nil {
+			return err
+		}
+
+		if len(assigned.reviewers) == missing {
+			if err := s.prRepo.RemoveFromAssignmentQueue(ctx, prID); err != nil {
+				return err
+			}
+		}
 	}
 
-	return reviewers
+	return nil
+}
+
+// assignment is the outcome of filling reviewer slots for a PR.
+type assignment struct {
+	reviewers []string
+	// fallback lists the reviewers taken from fallback teams
+	fallback []string
+	// sources says which strategy and team each reviewer came from
+	sources map[string]string
+	// short reports that capacity limits, rather than team size, left
+	// fewer reviewers than requested
+	short bool
+}
+
+// assignReviewers fills up to count reviewer slots from the active members of
+// the teams in settings in priority order, never picking anyone in exclude.
+func (s *PRService) assignReviewers(ctx context.Context, settings *reviewSettings, exclude []string, count int) (*assignment, error) {
+	result := &assignment{reviewers: []string{}, sources: make(map[string]string)}
+	for i, team := range settings.teams {
+		missing := count - len(result.reviewers)
+		if missing <= 0 {
+			break
+		}
+
+		teamUsers, err := s.userRepo.GetActiveUsersByTeam(ctx, team)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get team users: %w", err)
+		}
+
+		var candidates []*entity.User
+		for _, user := range teamUsers {
+			if !s.contains(exclude, user.UserID) && !s.contains(result.reviewers, user.UserID) {
+				candidates = append(candidates, user)
+			}
+		}
+
+		selected, short, err := s.pickReviewers(ctx, team, settings.strategy, candidates, missing)
+		if err != nil {
+			return nil, err
+		}
+
+		strategy := s.strategyFor(team, settings.strategy)
+		source := fmt.Sprintf("%s strategy in team %s", strategy, team)
+		if i > 0 {
+			source = fmt.Sprintf("%s strategy in fallback team %s", strategy, team)
+		}
+		for _, user := range selected {
+			result.reviewers = append(result.reviewers, user.UserID)
+			result.sources[user.UserID] = source
+			if i > 0 {
+				result.fallback = append(result.fallback, user.UserID)
+			}
+		}
+		result.short = result.short || short
+	}
+
+	result.short = result.short && len(result.reviewers) < count
+	return result, nil
+}
+
+// pickReviewers runs strategy, or the team's own when it is empty, over
+// candidates that still have review capacity. short reports that capacity
+// limits, rather than team size, left the PR with fewer than count reviewers.
+func (s *PRService) pickReviewers(ctx context.Context, teamName, strategy string, candidates []*entity.User, count int) ([]*entity.User, bool, error) {
+	load, err := openReviewCounts(ctx, s.prRepo, candidates)
+	if err != nil {
+		return nil, false, err
+	}
+
+	available := make([]*entity.User, 0, len(candidates))
+	for _, user := range candidates {
+		if user.MaxOpenReviews == nil || load[user.UserID] < *user.MaxOpenReviews {
+			available = append(available, user)
+		}
+	}
+
+	selected, err := s.selectorFor(teamName, strategy).Select(ctx, teamName, available, count)
+	if err != nil {
+		return nil, false, err
+	}
+
+	short := len(available) < len(candidates) && len(selected) < count
+	return selected, short, nil
+}
+
+func (s *PRService) mergePolicyFor(teamName string) MergePolicy {
+	if policy, ok := s.config.TeamMergePolicies[teamName]; ok {
+		return policy
+	}
+	return s.config.MergePolicy
+}
+
+// reviewSettings are the reviewer settings in effect for a PR, resolved from
+// its repository, then its team, then the global config.
+type reviewSettings struct {
+	reviewerCount int
+	// strategy overrides the strategy of every team when set
+	strategy string
+	// teams are the teams reviewers are drawn from; all but the first are
+	// fallbacks
+	teams       []string
+	mergePolicy MergePolicy
+}
+
+func (s *PRService) settingsFor(ctx context.Context, repositoryID, teamName string) (*reviewSettings, error) {
+	fallbacks, err := s.teamRepo.GetFallbacks(ctx, teamName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fallback teams: %w", err)
+	}
+
+	settings := &reviewSettings{
+		reviewerCount: s.config.ReviewerCount,
+		teams:         append([]string{teamName}, fallbacks...),
+		mergePolicy:   s.mergePolicyFor(teamName),
+	}
+	if repositoryID == "" {
+		return settings, nil
+	}
+
+	// PRs may name a repository nobody configured; it has no overrides
+	repository, err := s.repositoryRepo.GetByID(ctx, repositoryID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if repository.ReviewerCount != nil {
+		settings.reviewerCount = *repository.ReviewerCount
+	}
+	settings.strategy = repository.Strategy
+	if len(repository.EligibleTeams) > 0 {
+		settings.teams = repository.EligibleTeams
+	}
+	if repository.MergePolicy != nil {
+		settings.mergePolicy = MergePolicy(*repository.MergePolicy)
+	}
+
+	return settings, nil
+}
+
+func (s *PRService) selectorFor(teamName, strategy string) ReviewerSelector {
+	if strategy != "" {
+		return s.selectors.get(s.tenant, strategy)
+	}
+	if strategy, ok := s.config.TeamStrategies[teamName]; ok {
+		return s.selectors.get(s.tenant, strategy)
+	}
+	return s.selectors.get(s.tenant, s.config.Strategy)
+}
+
+func (s *PRService) strategyFor(teamName, strategy string) string {
+	if strategy == "" {
+		strategy = s.config.TeamStrategies[teamName]
+	}
+	if strategy == "" {
+		strategy = s.config.Strategy
+	}
+	if strategy == "" {
+		return StrategyRandom
+	}
+	return strategy
 }
 
 func (s *PRService) contains(slice []string, item string) bool {
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+)
+
+// prTransitions is the PR state machine: the statuses a PR may move to from
+// each status. MERGED is terminal.
+var prTransitions = map[entity.PRStatus][]entity.PRStatus{
+	entity.StatusDraft:  {entity.StatusOpen, entity.StatusClosed},
+	entity.StatusOpen:   {entity.StatusMerged, entity.StatusClosed, entity.StatusDraft},
+	entity.StatusClosed: {entity.StatusOpen},
+	entity.StatusMerged: {},
+}
+
+// transition moves pr to status to and stamps the matching timestamp. It
+// does not persist pr.
+func transition(pr *entity.PullRequest, to entity.PRStatus) error {
+	if !slices.Contains(prTransitions[pr.Status], to) {
+		return &domain.TransitionError{PullRequestID: pr.PullRequestID, From: pr.Status, To: to}
+	}
+
+	now := time.Now()
+	switch to {
+	case entity.StatusMerged:
+		pr.MergedAt = &now
+	case entity.StatusClosed:
+		pr.ClosedAt = &now
+	case entity.StatusOpen:
+		pr.ClosedAt = nil
+	}
+	pr.Status = to
+
+	return nil
+}
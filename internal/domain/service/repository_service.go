@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type RepositoryService struct {
+	repositoryRepo repo.RepositoryRepository
+	teamRepo       repo.TeamRepository
+	// reviewerGroups are the groups merge policies can require approval
+	// from, as configured for PRService.
+	reviewerGroups map[string][]string
+}
+
+func NewRepositoryService(repositoryRepo repo.RepositoryRepository, teamRepo repo.TeamRepository, reviewerGroups map[string][]string) *RepositoryService {
+	return &RepositoryService{
+		repositoryRepo: repositoryRepo,
+		teamRepo:       teamRepo,
+		reviewerGroups: reviewerGroups,
+	}
+}
+
+// ForTenant returns a copy of s that works on tenantID's data only.
+func (s *RepositoryService) ForTenant(tenantID string) *RepositoryService {
+	return &RepositoryService{
+		repositoryRepo: s.repositoryRepo.ForTenant(tenantID),
+		teamRepo:       s.teamRepo.ForTenant(tenantID),
+		reviewerGroups: s.reviewerGroups,
+	}
+}
+
+// SaveRepository registers a repository or replaces its reviewer settings.
+func (s *RepositoryService) SaveRepository(ctx context.Context, repository *entity.Repository) error {
+	if repository.RepositoryID == "" {
+		return domain.Invalid(domain.CodeBadRequest, "repository_id is required")
+	}
+	if repository.ReviewerCount != nil && *repository.ReviewerCount < 0 {
+		return domain.Invalid(domain.CodeBadRequest, "reviewer_count must not be negative")
+	}
+	if repository.Strategy != "" && !slices.Contains(strategies, repository.Strategy) {
+		return domain.Invalid(domain.CodeBadRequest, "unknown reviewer strategy: %s", repository.Strategy)
+	}
+	if policy := repository.MergePolicy; policy != nil {
+		if policy.MinApprovals < 0 {
+			return domain.Invalid(domain.CodeBadRequest, "min_approvals must not be negative")
+		}
+		// an unknown group has no members, so no PR could ever be merged
+		if _, ok := s.reviewerGroups[policy.RequiredGroup]; policy.RequiredGroup != "" && !ok {
+			return domain.Invalid(domain.CodeBadRequest, "unknown reviewer group: %s", policy.RequiredGroup)
+		}
+	}
+
+	for i, team := range repository.EligibleTeams {
+		if slices.Contains(repository.EligibleTeams[:i], team) {
+			return domain.Invalid(domain.CodeBadRequest, "team %s is listed twice", team)
+		}
+		exists, err := s.teamRepo.Exists(ctx, team)
+		if err != nil {
+			return fmt.Errorf("failed to check team existence: %w", err)
+		}
+		if !exists {
+			return domain.NotFound("team %s not found", team)
+		}
+	}
+
+	return s.repositoryRepo.Save(ctx, repository)
+}
+
+func (s *RepositoryService) GetRepository(ctx context.Context, repositoryID string) (*entity.Repository, error) {
+	return s.repositoryRepo.GetByID(ctx, repositoryID)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+const (
+	StrategyRandom      = "random"
+	StrategyRoundRobin  = "round-robin"
+	StrategyLeastLoaded = "least-loaded"
+	StrategyWeighted    = "weighted"
+)
+
+var strategies = []string{StrategyRandom, StrategyRoundRobin, StrategyLeastLoaded, StrategyWeighted}
+
+// ReviewerSelector picks up to count reviewers out of candidates for a PR
+// authored in teamName.
+type ReviewerSelector interface {
+	Select(ctx context.Context, teamName string, candidates []*entity.User, count int) ([]*entity.User, error)
+}
+
+func newReviewerSelector(strategy string, rng *rand.Rand, prRepo repo.PRRepository, weights map[string]int) (ReviewerSelector, error) {
+	switch strategy {
+	case "", StrategyRandom:
+		return &randomSelector{rng: rng}, nil
+	case StrategyRoundRobin:
+		return &roundRobinSelector{last: make(map[string]string)}, nil
+	case StrategyLeastLoaded:
+		return &leastLoadedSelector{rng: rng, prRepo: prRepo}, nil
+	case StrategyWeighted:
+		return &weightedSelector{rng: rng, weights: weights}, nil
+	default:
+		return nil, fmt.Errorf("unknown reviewer strategy: %s", strategy)
+	}
+}
+
+type randomSelector struct {
+	rng *rand.Rand
+}
+
+func (s *randomSelector) Select(ctx context.Context, teamName string, candidates []*entity.User, count int) ([]*entity.User, error) {
+	shuffled := make([]*entity.User, len(candidates))
+	copy(shuffled, candidates)
+	s.rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	return shuffled[:min(len(shuffled), count)], nil
+}
+
+// roundRobinSelector walks the team ordered by user_id, continuing after the
+// last reviewer it handed out for that team.
+type roundRobinSelector struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+func (s *roundRobinSelector) Select(ctx context.Context, teamName string, candidates []*entity.User, count int) ([]*entity.User, error) {
+	if len(candidates) == 0 {
+		return []*entity.User{}, nil
+	}
+
+	sorted := make([]*entity.User, len(candidates))
+	copy(sorted, candidates)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].UserID < sorted[j].UserID
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i].UserID > s.last[teamName]
+	})
+
+	count = min(len(sorted), count)
+	selected := make([]*entity.User, count)
+	for i := 0; i < count; i++ {
+		selected[i] = sorted[(start+i)%len(sorted)]
+	}
+	if count > 0 {
+		s.last[teamName] = selected[count-1].UserID
+	}
+
+	return selected, nil
+}
+
+// leastLoadedSelector prefers candidates with the fewest OPEN pull requests
+// to review, breaking ties randomly.
+type leastLoadedSelector struct {
+	rng    *rand.Rand
+	prRepo repo.PRRepository
+}
+
+func (s *leastLoadedSelector) Select(ctx context.Context, teamName string, candidates []*entity.User, count int) ([]*entity.User, error) {
+	load, err := openReviewCounts(ctx, s.prRepo, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	shuffled := make([]*entity.User, len(candidates))
+	copy(shuffled, candidates)
+	s.rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	sort.SliceStable(shuffled, func(i, j int) bool {
+		return load[shuffled[i].UserID] < load[shuffled[j].UserID]
+	})
+
+	return shuffled[:min(len(shuffled), count)], nil
+}
+
+// weightedSelector draws reviewers without replacement with probability
+// proportional to their configured weight. Users without a weight get 1,
+// users with a non-positive weight are never picked.
+type weightedSelector struct {
+	rng     *rand.Rand
+	weights map[string]int
+}
+
+func (s *weightedSelector) Select(ctx context.Context, teamName string, candidates []*entity.User, count int) ([]*entity.User, error) {
+	pool := make([]*entity.User, 0, len(candidates))
+	total := 0
+	for _, user := range candidates {
+		if w := s.weight(user.UserID); w > 0 {
+			pool = append(pool, user)
+			total += w
+		}
+	}
+
+	var selected []*entity.User
+	for len(selected) < count && len(pool) > 0 {
+		r := s.rng.Intn(total)
+		for i, user := range pool {
+			w := s.weight(user.UserID)
+			if r < w {
+				selected = append(selected, user)
+				pool = append(pool[:i], pool[i+1:]...)
+				total -= w
+				break
+			}
+			r -= w
+		}
+	}
+
+	return selected, nil
+}
+
+func (s *weightedSelector) weight(userID string) int {
+	if w, ok := s.weights[userID]; ok {
+		return w
+	}
+	return 1
+}
+
+// lockedSource makes a seeded source safe for concurrent use, the way the
+// top-level math/rand functions are. A *rand.Rand over it is safe too, as
+// long as its Read method is not used.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// selectorSet holds one selector per strategy and tenant, so stateful
+// selectors (round-robin) are shared across the teams of a tenant but never
+// across tenants. Selectors are built on first use.
+type selectorSet struct {
+	mu       sync.Mutex
+	rng      *rand.Rand
+	prRepo   repo.PRRepository
+	weights  map[string]int
+	names    []string
+	byTenant map[string]map[string]ReviewerSelector
+}
+
+func newSelectorSet(rng *rand.Rand, prRepo repo.PRRepository, weights map[string]int, names []string) (*selectorSet, error) {
+	// build them once up front so a misconfigured strategy fails at startup
+	for _, strategy := range names {
+		if _, err := newReviewerSelector(strategy, rng, prRepo, weights); err != nil {
+			return nil, err
+		}
+	}
+
+	return &selectorSet{
+		rng:      rng,
+		prRepo:   prRepo,
+		weights:  weights,
+		names:    names,
+		byTenant: make(map[string]map[string]ReviewerSelector),
+	}, nil
+}
+
+func (s *selectorSet) get(tenantID, strategy string) ReviewerSelector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selectors, ok := s.byTenant[tenantID]
+	if !ok {
+		selectors = make(map[string]ReviewerSelector, len(s.names))
+		prRepo := s.prRepo.ForTenant(tenantID)
+		for _, name := range s.names {
+			// names were validated by newSelectorSet
+			selectors[name], _ = newReviewerSelector(name, s.rng, prRepo, s.weights)
+		}
+		s.byTenant[tenantID] = selectors
+	}
+
+	return selectors[strategy]
+}
+
+// openReviewCounts returns the number of OPEN pull requests each candidate is
+// reviewing, querying once per team present in candidates.
+func openReviewCounts(ctx context.Context, prRepo repo.PRRepository, candidates []*entity.User) (map[string]int, error) {
+	load := make(map[string]int, len(candidates))
+	fetched := make(map[string]bool)
+	for _, user := range candidates {
+		if fetched[user.TeamName] {
+			continue
+		}
+		counts, err := prRepo.GetOpenReviewCounts(ctx, user.TeamName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reviewer load: %w", err)
+		}
+		for userID, count := range counts {
+			load[userID] = count
+		}
+		fetched[user.TeamName] = true
+	}
+	return load, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type StatsService struct {
+	prRepo repo.PRRepository
+}
+
+func NewStatsService(prRepo repo.PRRepository) *StatsService {
+	return &StatsService{prRepo: prRepo}
+}
+
+// ForTenant returns a copy of s that reports on tenantID's data only.
+func (s *StatsService) ForTenant(tenantID string) *StatsService {
+	return &StatsService{prRepo: s.prRepo.ForTenant(tenantID)}
+}
+
+func (s *StatsService) GetReviewerStats(ctx context.Context, filter entity.StatsFilter) ([]*entity.ReviewerStats, error) {
+	if err := checkWindow(filter); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.prRepo.GetReviewerStats(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviewer stats: %w", err)
+	}
+	return stats, nil
+}
+
+func (s *StatsService) GetTeamStats(ctx context.Context, filter entity.StatsFilter) ([]*entity.TeamStats, error) {
+	if err := checkWindow(filter); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.prRepo.GetTeamStats(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team stats: %w", err)
+	}
+	return stats, nil
+}
+
+func checkWindow(filter entity.StatsFilter) error {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return domain.Invalid(domain.CodeBadRequest, "from must be before to")
+	}
+	return nil
+}
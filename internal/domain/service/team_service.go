@@ -1,7 +1,9 @@
 package service
 
 import (
+    "context"
     "fmt"
+    "github.com/shmul/avito-task/internal/domain"
     "github.com/shmul/avito-task/internal/domain/entity"
     "github.com/shmul/avito-task/internal/domain/repo"
 )
@@ -18,26 +20,77 @@ func NewTeamService(teamRepo repo.TeamRepository, userRepo repo.UserRepository)
     }
 }
 
-func (s *TeamService) CreateTeam(team *entity.Team) error {
-    exists, err := s.teamRepo.Exists(team.TeamName)
+// ForTenant returns a copy of s that works on tenantID's data only.
+func (s *TeamService) ForTenant(tenantID string) *TeamService {
+    return &TeamService{
+        teamRepo: s.teamRepo.ForTenant(tenantID),
+        userRepo: s.userRepo.ForTenant(tenantID),
+    }
+}
+
+func (s *TeamService) CreateTeam(ctx context.Context, team *entity.Team) error {
+    exists, err := s.teamRepo.Exists(ctx, team.TeamName)
     if err != nil {
         return fmt.Errorf("failed to check team existence: %w", err)
     }
     if exists {
-        return fmt.Errorf("team already exists: %s", team.TeamName)
+        return domain.Invalid(domain.CodeTeamExists, "team %s already exists", team.TeamName)
+    }
+
+    if err := s.checkFallbacks(ctx, team.TeamName, team.FallbackTeams); err != nil {
+        return err
     }
 
-    if err := s.teamRepo.Create(team); err != nil {
+    if err := s.teamRepo.Create(ctx, team); err != nil {
         return fmt.Errorf("failed to create team: %w", err)
     }
 
     return nil
 }
 
-func (s *TeamService) GetTeam(teamName string) (*entity.Team, error) {
-    team, err := s.teamRepo.GetByName(teamName)
+func (s *TeamService) GetTeam(ctx context.Context, teamName string) (*entity.Team, error) {
+    team, err := s.teamRepo.GetByName(ctx, teamName)
     if err != nil {
         return nil, fmt.Errorf("failed to get team: %w", err)
     }
     return team, nil
+}
+
+func (s *TeamService) SetFallbacks(ctx context.Context, teamName string, fallbackTeams []string) (*entity.Team, error) {
+    exists, err := s.teamRepo.Exists(ctx, teamName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to check team existence: %w", err)
+    }
+    if !exists {
+        return nil, domain.NotFound("team %s not found", teamName)
+    }
+
+    if err := s.checkFallbacks(ctx, teamName, fallbackTeams); err != nil {
+        return nil, err
+    }
+
+    if err := s.teamRepo.SetFallbacks(ctx, teamName, fallbackTeams); err != nil {
+        return nil, fmt.Errorf("failed to set fallback teams: %w", err)
+    }
+
+    return s.GetTeam(ctx, teamName)
+}
+
+func (s *TeamService) checkFallbacks(ctx context.Context, teamName string, fallbackTeams []string) error {
+    seen := make(map[string]bool)
+    for _, fallback := range fallbackTeams {
+        if fallback == teamName || seen[fallback] {
+            return domain.Invalid(domain.CodeBadRequest, "invalid fallback team: %s", fallback)
+        }
+        seen[fallback] = true
+
+        exists, err := s.teamRepo.Exists(ctx, fallback)
+        if err != nil {
+            return fmt.Errorf("failed to check team existence: %w", err)
+        }
+        if !exists {
+            return domain.Invalid(domain.CodeBadRequest, "fallback team %s not found", fallback)
+        }
+    }
+    return nil
 }
\ No newline at end of file
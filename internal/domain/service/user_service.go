@@ -1,36 +1,158 @@
 package service
 
 import (
+    "context"
     "fmt"
+    "slices"
+    "github.com/shmul/avito-task/internal/domain"
     "github.com/shmul/avito-task/internal/domain/entity"
     "github.com/shmul/avito-task/internal/domain/repo"
 )
 
 type UserService struct {
-    userRepo repo.UserRepository
-    teamRepo repo.TeamRepository
+    userRepo  repo.UserRepository
+    teamRepo  repo.TeamRepository
+    prService *PRService
+    tx        repo.Transactor
+    principal *entity.Principal
 }
 
-func NewUserService(userRepo repo.UserRepository, teamRepo repo.TeamRepository) *UserService {
+// SetActiveResult is a user after SetUserActive together with the OPEN PRs a
+// deactivation took them off.
+type SetActiveResult struct {
+    User         *entity.User
+    Reassigned   []Reassignment
+    Unassignable []string
+}
+
+// DeactivationResult lists the users DeactivateUsers deactivated and where
+// their OPEN reviews went.
+type DeactivationResult struct {
+    Users        []*entity.User
+    Reassigned   []Reassignment
+    Unassignable []string
+}
+
+func NewUserService(userRepo repo.UserRepository, teamRepo repo.TeamRepository, prService *PRService, tx repo.Transactor) *UserService {
+    return &UserService{
+        userRepo:  userRepo,
+        teamRepo:  teamRepo,
+        prService: prService,
+        tx:        tx,
+    }
+}
+
+// ForTenant returns a copy of s that works on tenantID's data only.
+func (s *UserService) ForTenant(tenantID string) *UserService {
     return &UserService{
-        userRepo: userRepo,
-        teamRepo: teamRepo,
+        userRepo:  s.userRepo.ForTenant(tenantID),
+        teamRepo:  s.teamRepo.ForTenant(tenantID),
+        prService: s.prService.ForTenant(tenantID),
+        tx:        s.tx.ForTenant(tenantID),
+        principal: s.principal,
+    }
+}
+
+// As returns a copy of s acting on behalf of principal, which may only
+// change the activity of teams it manages.
+func (s *UserService) As(principal *entity.Principal) *UserService {
+    acting := *s
+    acting.principal = principal
+    return &acting
+}
+
+// actorID is the user s acts as, recorded on the reassignments it makes.
+func (s *UserService) actorID() string {
+    if s.principal == nil {
+        return ""
+    }
+    return s.principal.UserID
+}
+
+func (s *UserService) authorize(teamName string) error {
+    if s.principal != nil && !s.principal.CanManageTeam(teamName) {
+        return domain.Forbidden("not allowed to manage team %s", teamName)
     }
+    return nil
 }
 
-func (s *UserService) SetUserActive(userID string, isActive bool) (*entity.User, error) {
-    exists, err := s.userRepo.Exists(userID)
+// SetUserActive flips a user's is_active flag. Deactivating a user also
+// replaces them on every OPEN PR they review, or drops them where no
+// candidate exists, in the same transaction.
+func (s *UserService) SetUserActive(ctx context.Context, userID string, isActive bool) (*SetActiveResult, error) {
+    user, err := s.userRepo.GetByID(ctx, userID)
     if err != nil {
-        return nil, fmt.Errorf("failed to check user existence: %w", err)
+        return nil, fmt.Errorf("failed to get user: %w", err)
+    }
+    if err := s.authorize(user.TeamName); err != nil {
+        return nil, err
+    }
+
+    result := &SetActiveResult{}
+    err = s.tx.WithinTx(ctx, func(r repo.Repositories) error {
+        user, err := r.Users.SetActive(ctx, userID, isActive)
+        if err != nil {
+            return fmt.Errorf("failed to set user active: %w", err)
+        }
+        result.User = user
+
+        if isActive {
+            return nil
+        }
+
+        result.Reassigned, result.Unassignable, err = s.prService.bind(r).releaseReviewer(ctx, userID, s.actorID())
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    return result, nil
+}
+
+// DeactivateUsers deactivates userIDs in teamName, or the whole team when
+// userIDs is empty, and moves their OPEN reviews to the remaining active
+// users in one transaction.
+func (s *UserService) DeactivateUsers(ctx context.Context, teamName string, userIDs []string) (*DeactivationResult, error) {
+    exists, err := s.teamRepo.Exists(ctx, teamName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to check team existence: %w", err)
     }
     if !exists {
-        return nil, fmt.Errorf("user not found: %s", userID)
+        return nil, domain.NotFound("team %s not found", teamName)
+    }
+    if err := s.authorize(teamName); err != nil {
+        return nil, err
     }
 
-    user, err := s.userRepo.SetActive(userID, isActive)
+    result := &DeactivationResult{}
+    err = s.tx.WithinTx(ctx, func(r repo.Repositories) error {
+        users, err := r.Users.SetTeamActive(ctx, teamName, userIDs, false)
+        if err != nil {
+            return err
+        }
+
+        deactivated := make([]string, 0, len(users))
+        for _, user := range users {
+            deactivated = append(deactivated, user.UserID)
+        }
+        for _, userID := range userIDs {
+            if !slices.Contains(deactivated, userID) {
+                return domain.NotFound("user %s not found in team %s", userID, teamName)
+            }
+        }
+        result.Users = users
+
+        if len(deactivated) == 0 {
+            return nil
+        }
+
+        result.Reassigned, result.Unassignable, err = s.prService.bind(r).releaseReviewers(ctx, teamName, deactivated, s.actorID())
+        return err
+    })
     if err != nil {
-        return nil, fmt.Errorf("failed to set user active: %w", err)
+        return nil, err
     }
 
-    return user, nil
+    return result, nil
 }
\ No newline at end of file
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+// VCS providers PRs can be ingested from.
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+var providers = []string{ProviderGitHub, ProviderGitLab}
+
+// VCSAction is a provider-neutral PR lifecycle event.
+type VCSAction string
+
+const (
+	VCSOpened   VCSAction = "opened"
+	VCSUpdated  VCSAction = "updated"
+	VCSReady    VCSAction = "ready_for_review"
+	VCSMerged   VCSAction = "merged"
+	VCSClosed   VCSAction = "closed"
+	VCSReopened VCSAction = "reopened"
+)
+
+// VCSEvent is a provider webhook translated into PR terms. Providers parse
+// their payloads into it; VCSService applies it the same way for all of them.
+// AuthorLogin is empty when the payload does not say who authored the PR,
+// and such events cannot create it.
+type VCSEvent struct {
+	Provider    string
+	Project     string
+	Number      int
+	Action      VCSAction
+	Title       string
+	AuthorLogin string
+	// ActorLogin is whoever triggered the event, e.g. the merger.
+	ActorLogin string
+	Draft      bool
+}
+
+// PullRequestID is the stable PR identity across providers,
+// provider:project:number.
+func (e *VCSEvent) PullRequestID() string {
+	return fmt.Sprintf("%s:%s:%d", e.Provider, e.Project, e.Number)
+}
+
+// RepositoryID identifies the project across providers, provider:project.
+func (e *VCSEvent) RepositoryID() string {
+	return fmt.Sprintf("%s:%s", e.Provider, e.Project)
+}
+
+type VCSService struct {
+	prService *PRService
+	prRepo    repo.PRRepository
+	userRepo  repo.UserRepository
+}
+
+func NewVCSService(prService *PRService, prRepo repo.PRRepository, userRepo repo.UserRepository) *VCSService {
+	return &VCSService{
+		prService: prService,
+		prRepo:    prRepo,
+		userRepo:  userRepo,
+	}
+}
+
+// ForTenant returns a copy of s that ingests into tenantID's data only.
+func (s *VCSService) ForTenant(tenantID string) *VCSService {
+	return &VCSService{
+		prService: s.prService.ForTenant(tenantID),
+		prRepo:    s.prRepo.ForTenant(tenantID),
+		userRepo:  s.userRepo.ForTenant(tenantID),
+	}
+}
+
+// LinkAccount maps a provider login to a user so ingested PRs get an author.
+func (s *VCSService) LinkAccount(ctx context.Context, provider, login, userID string) error {
+	if !slices.Contains(providers, provider) {
+		return domain.Invalid(domain.CodeBadRequest, "unknown provider: %s", provider)
+	}
+	if login == "" {
+		return domain.Invalid(domain.CodeBadRequest, "login is required")
+	}
+
+	exists, err := s.userRepo.Exists(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check user existence: %w", err)
+	}
+	if !exists {
+		return domain.NotFound("user %s not found", userID)
+	}
+
+	return s.userRepo.LinkVCSAccount(ctx, provider, login, userID)
+}
+
+// Apply brings the PR in line with event. Providers redeliver and reorder
+// webhooks, so every action is idempotent: one that is already reflected in
+// the PR's state is a no-op returning the PR as it is.
+func (s *VCSService) Apply(ctx context.Context, event *VCSEvent) (*entity.PullRequest, error) {
+	prID := event.PullRequestID()
+
+	actorID, err := s.userID(ctx, event.Provider, event.ActorLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return s.create(ctx, event, actorID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pr: %w", err)
+	}
+
+	switch event.Action {
+	case VCSOpened, VCSUpdated, VCSReady:
+		if pr.Status == entity.StatusDraft && !event.Draft {
+			return s.prService.MarkReady(ctx, prID, actorID)
+		}
+		if pr.Status == entity.StatusOpen && event.Draft {
+			return s.prService.MarkDraft(ctx, prID, actorID)
+		}
+	case VCSReopened:
+		if pr.Status == entity.StatusClosed {
+			return s.prService.ReopenPR(ctx, prID, actorID)
+		}
+	case VCSClosed:
+		if pr.Status == entity.StatusDraft || pr.Status == entity.StatusOpen {
+			return s.prService.ClosePR(ctx, prID, actorID)
+		}
+	case VCSMerged:
+		return s.prService.MergeExternal(ctx, prID, actorID)
+	}
+
+	return pr, nil
+}
+
+// create registers a PR first seen through event on behalf of actorID. Only
+// events for a live PR that name its author create one; a merge or close of
+// an unknown PR is not tracked.
+func (s *VCSService) create(ctx context.Context, event *VCSEvent, actorID string) (*entity.PullRequest, error) {
+	switch event.Action {
+	case VCSOpened, VCSUpdated, VCSReady, VCSReopened:
+	default:
+		return nil, domain.NotFound("PR %s not found", event.PullRequestID())
+	}
+	if event.AuthorLogin == "" {
+		return nil, domain.NotFound("PR %s not found and the event does not name its author", event.PullRequestID())
+	}
+
+	author, err := s.userRepo.GetByVCSLogin(ctx, event.Provider, event.AuthorLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.prService.CreatePR(ctx, CreatePRInput{
+		PullRequestID:   event.PullRequestID(),
+		PullRequestName: event.Title,
+		AuthorID:        author.UserID,
+		Draft:           event.Draft,
+		RepositoryID:    event.RepositoryID(),
+		ActorID:         actorID,
+	})
+}
+
+// userID resolves a provider login, leaving unlinked logins anonymous.
+func (s *VCSService) userID(ctx context.Context, provider, login string) (string, error) {
+	if login == "" {
+		return "", nil
+	}
+
+	user, err := s.userRepo.GetByVCSLogin(ctx, provider, login)
+	if errors.Is(err, domain.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.UserID, nil
+}
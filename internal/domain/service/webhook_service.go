@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type WebhookService struct {
+	webhookRepo repo.WebhookRepository
+}
+
+func NewWebhookService(webhookRepo repo.WebhookRepository) *WebhookService {
+	return &WebhookService{webhookRepo: webhookRepo}
+}
+
+// ForTenant returns a copy of s that works on tenantID's webhooks only.
+func (s *WebhookService) ForTenant(tenantID string) *WebhookService {
+	return &WebhookService{webhookRepo: s.webhookRepo.ForTenant(tenantID)}
+}
+
+// RegisterWebhook subscribes rawURL to eventTypes, or to every event when
+// eventTypes is empty. Without a secret one is generated; the returned
+// webhook is the only place it is reported.
+func (s *WebhookService) RegisterWebhook(ctx context.Context, rawURL, secret string, eventTypes []string) (*entity.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, domain.Invalid(domain.CodeBadRequest, "url must be an absolute http(s) URL")
+	}
+
+	for _, eventType := range eventTypes {
+		if !slices.Contains(entity.WebhookEventTypes, eventType) {
+			return nil, domain.Invalid(domain.CodeBadRequest, "unknown event type: %s", eventType)
+		}
+	}
+
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	webhook := &entity.Webhook{
+		URL:        rawURL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		IsActive:   true,
+	}
+	if err := s.webhookRepo.Create(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to register webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+func (s *WebhookService) GetFailedDeliveries(ctx context.Context, limit int) ([]*entity.Delivery, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	deliveries, err := s.webhookRepo.GetFailedDeliveries(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failed deliveries: %w", err)
+	}
+	return deliveries, nil
+}
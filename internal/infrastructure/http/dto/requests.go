@@ -1,14 +1,28 @@
 package dto
 
+import "github.com/shmul/avito-task/internal/domain/entity"
+
 type CreateTeamRequest struct {
-    TeamName string        `json:"team_name"`
-    Members  []TeamMember  `json:"members"`
+    TeamName      string        `json:"team_name"`
+    Members       []TeamMember  `json:"members"`
+    FallbackTeams []string      `json:"fallback_teams,omitempty"`
 }
 
 type TeamMember struct {
-    UserID   string `json:"user_id"`
-    Username string `json:"username"`
-    IsActive bool   `json:"is_active"`
+    UserID         string `json:"user_id"`
+    Username       string `json:"username"`
+    IsActive       bool   `json:"is_active"`
+    MaxOpenReviews *int   `json:"max_open_reviews,omitempty"`
+}
+
+type SetFallbacksRequest struct {
+    TeamName      string   `json:"team_name"`
+    FallbackTeams []string `json:"fallback_teams"`
+}
+
+type DeactivateUsersRequest struct {
+    TeamName string   `json:"team_name"`
+    UserIDs  []string `json:"user_ids,omitempty"`
 }
 
 type SetUserActiveRequest struct {
@@ -17,13 +31,21 @@ type SetUserActiveRequest struct {
 }
 
 type CreatePRRequest struct {
-    PullRequestID   string `json:"pull_request_id"`
-    PullRequestName string `json:"pull_request_name"`
-    AuthorID        string `json:"author_id"`
+    PullRequestID   string   `json:"pull_request_id"`
+    PullRequestName string   `json:"pull_request_name"`
+    AuthorID        string   `json:"author_id"`
+    Draft           bool     `json:"draft,omitempty"`
+    RepositoryID    string   `json:"repository_id,omitempty"`
+    ChangedFiles    []string `json:"changed_files,omitempty"`
 }
 
 type MergePRRequest struct {
     PullRequestID string `json:"pull_request_id"`
+    Force         bool   `json:"force,omitempty"`
+}
+
+type ChangePRStatusRequest struct {
+    PullRequestID string `json:"pull_request_id"`
 }
 
 type ReassignReviewerRequest struct {
@@ -31,10 +53,52 @@ type ReassignReviewerRequest struct {
     OldUserID     string `json:"old_user_id"`
 }
 
+type ReviewPRRequest struct {
+    PullRequestID string `json:"pull_request_id"`
+    ReviewerID    string `json:"reviewer_id"`
+    State         string `json:"state"`
+}
+
+type RegisterWebhookRequest struct {
+    URL        string   `json:"url"`
+    Secret     string   `json:"secret,omitempty"`
+    EventTypes []string `json:"event_types,omitempty"`
+}
+
+type LinkVCSAccountRequest struct {
+    Provider string `json:"provider"`
+    Login    string `json:"login"`
+    UserID   string `json:"user_id"`
+}
+
 type GetTeamRequest struct {
     TeamName string `json:"team_name" form:"team_name"`
 }
 
 type GetUserReviewRequest struct {
     UserID string `json:"user_id" form:"user_id"`
-}
\ No newline at end of file
+}
+
+type UploadCodeownersRequest struct {
+    RepositoryID string `json:"repository_id"`
+    Content      string `json:"content"`
+}
+
+type SaveRepositoryRequest struct {
+    RepositoryID  string              `json:"repository_id"`
+    ReviewerCount *int                `json:"reviewer_count,omitempty"`
+    Strategy      string              `json:"strategy,omitempty"`
+    EligibleTeams []string            `json:"eligible_teams,omitempty"`
+    MergePolicy   *entity.MergePolicy `json:"merge_policy,omitempty"`
+}
+
+type IssueAPIKeyRequest struct {
+    Name     string `json:"name"`
+    Role     string `json:"role"`
+    UserID   string `json:"user_id,omitempty"`
+    TeamName string `json:"team_name,omitempty"`
+}
+
+type RevokeAPIKeyRequest struct {
+    KeyID int64 `json:"key_id"`
+}
@@ -1,6 +1,9 @@
 package dto
 
-import "github.com/shmul/avito-task/internal/domain/entity"
+import (
+    "github.com/shmul/avito-task/internal/domain/codeowners"
+    "github.com/shmul/avito-task/internal/domain/entity"
+)
 
 type ErrorResponse struct {
     Error ErrorDetails `json:"error"`
@@ -9,6 +12,7 @@ type ErrorResponse struct {
 type ErrorDetails struct {
     Code    string `json:"code"`
     Message string `json:"message"`
+    Details any    `json:"details,omitempty"`
 }
 
 type TeamResponse struct {
@@ -16,7 +20,21 @@ type TeamResponse struct {
 }
 
 type UserResponse struct {
-    User *entity.User `json:"user"`
+    User         *entity.User    `json:"user"`
+    Reassigned   []Reassignment  `json:"reassigned,omitempty"`
+    Unassignable []string        `json:"unassignable,omitempty"`
+}
+
+type Reassignment struct {
+    PullRequestID string `json:"pull_request_id"`
+    OldReviewerID string `json:"old_reviewer_id"`
+    ReplacedBy    string `json:"replaced_by"`
+}
+
+type DeactivateUsersResponse struct {
+    Users        []*entity.User `json:"users"`
+    Reassigned   []Reassignment `json:"reassigned"`
+    Unassignable []string       `json:"unassignable"`
 }
 
 type PRResponse struct {
@@ -31,4 +49,47 @@ type ReassignResponse struct {
 type UserPRsResponse struct {
     UserID        string                 `json:"user_id"`
     PullRequests  []*entity.PullRequest `json:"pull_requests"`
-}
\ No newline at end of file
+}
+
+type PRHistoryResponse struct {
+    PullRequestID string                    `json:"pull_request_id"`
+    Events        []*entity.AssignmentEvent `json:"events"`
+}
+
+type ReviewerStatsResponse struct {
+    Reviewers []*entity.ReviewerStats `json:"reviewers"`
+}
+
+type TeamStatsResponse struct {
+    Teams []*entity.TeamStats `json:"teams"`
+}
+
+type WebhookResponse struct {
+    Webhook *entity.Webhook `json:"webhook"`
+}
+
+type DeliveriesResponse struct {
+    Deliveries []*entity.Delivery `json:"deliveries"`
+}
+
+type VCSEventResponse struct {
+    PullRequestID string              `json:"pull_request_id,omitempty"`
+    Action        string              `json:"action,omitempty"`
+    Ignored       bool                `json:"ignored,omitempty"`
+    PR            *entity.PullRequest `json:"pr,omitempty"`
+}
+
+type CodeownersResponse struct {
+    RepositoryID string             `json:"repository_id"`
+    Rules        []*codeowners.Rule `json:"rules"`
+}
+
+type RepositoryResponse struct {
+    Repository *entity.Repository `json:"repository"`
+}
+
+// APIKeyResponse carries the raw key only when it is issued.
+type APIKeyResponse struct {
+    Key    *entity.APIKey `json:"key"`
+    APIKey string         `json:"api_key,omitempty"`
+}
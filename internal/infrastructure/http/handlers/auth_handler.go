@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/http/dto"
+	"github.com/shmul/avito-task/internal/infrastructure/http/middleware"
+)
+
+type AuthHandler struct {
+	authService *service.AuthService
+}
+
+func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+	}
+}
+
+func (h *AuthHandler) IssueKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req dto.IssueAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	key := &entity.APIKey{
+		Name:     req.Name,
+		Role:     entity.Role(req.Role),
+		UserID:   req.UserID,
+		TeamName: req.TeamName,
+	}
+	rawKey, err := h.authService.ForTenant(tenantID(r)).IssueKey(r.Context(), key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// the response carries a secret, it must not be kept anywhere
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.APIKeyResponse{Key: key, APIKey: rawKey})
+}
+
+func (h *AuthHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req dto.RevokeAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.authService.ForTenant(tenantID(r)).RevokeKey(r.Context(), req.KeyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.APIKeyResponse{Key: key})
+}
+
+// principal returns who the request is made by, as resolved by
+// middleware.Auth.
+func principal(r *http.Request) *entity.Principal {
+	return middleware.PrincipalFrom(r.Context())
+}
+
+// actorID returns the user the request acts as, or "" when its credentials
+// are not tied to a user. Handlers never take the actor from the body.
+func actorID(r *http.Request) string {
+	if p := principal(r); p != nil {
+		return p.UserID
+	}
+	return ""
+}
+
+// mayActAs reports whether the request may name userID as the user doing
+// something, like authoring a PR. Only admins act for others; everyone else
+// speaks for the user their credentials are tied to, and credentials tied
+// to no user speak for nobody.
+func mayActAs(r *http.Request, userID string) bool {
+	p := principal(r)
+	if p == nil {
+		return false
+	}
+	return (p.UserID != "" && p.UserID == userID) || p.Role.Allows(entity.RoleAdmin)
+}
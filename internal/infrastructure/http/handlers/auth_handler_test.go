@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/infrastructure/http/middleware"
+)
+
+func requestAs(p *entity.Principal, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if p != nil {
+		r = r.WithContext(middleware.WithPrincipal(r.Context(), p))
+	}
+	return r
+}
+
+func TestMayActAs(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *entity.Principal
+		userID    string
+		want      bool
+	}{
+		{name: "member as themselves", principal: &entity.Principal{UserID: "u1", Role: entity.RoleMember}, userID: "u1", want: true},
+		{name: "member as someone else", principal: &entity.Principal{UserID: "u1", Role: entity.RoleMember}, userID: "u2"},
+		{name: "team lead as someone else", principal: &entity.Principal{UserID: "u1", Role: entity.RoleTeamLead}, userID: "u2"},
+		{name: "member key without a user", principal: &entity.Principal{Role: entity.RoleMember}, userID: "u2"},
+		{name: "team lead key without a user", principal: &entity.Principal{Role: entity.RoleTeamLead}, userID: "u2"},
+		{name: "key without a user naming nobody", principal: &entity.Principal{Role: entity.RoleMember}, userID: ""},
+		{name: "admin as someone else", principal: &entity.Principal{UserID: "u1", Role: entity.RoleAdmin}, userID: "u2", want: true},
+		{name: "admin key without a user", principal: &entity.Principal{Role: entity.RoleAdmin}, userID: "u2", want: true},
+		{name: "no principal", userID: "u1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mayActAs(requestAs(tt.principal, ""), tt.userID); got != tt.want {
+				t.Fatalf("mayActAs(%q) = %v, want %v", tt.userID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReviewPRRequiresAUser(t *testing.T) {
+	body := `{"pull_request_id":"pr-1","reviewer_id":"u2","state":"APPROVED"}`
+	h := NewPRHandler(nil)
+
+	for _, p := range []*entity.Principal{
+		{Role: entity.RoleMember},
+		{Role: entity.RoleAdmin},
+		{UserID: "u1", Role: entity.RoleMember},
+	} {
+		rec := httptest.NewRecorder()
+		h.ReviewPR(rec, requestAs(p, body))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("review by %+v: status = %d, want %d", p, rec.Code, http.StatusForbidden)
+		}
+	}
+}
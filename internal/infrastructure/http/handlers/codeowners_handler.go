@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/http/dto"
+)
+
+type CodeownersHandler struct {
+	codeownersService *service.CodeownersService
+}
+
+func NewCodeownersHandler(codeownersService *service.CodeownersService) *CodeownersHandler {
+	return &CodeownersHandler{
+		codeownersService: codeownersService,
+	}
+}
+
+func (h *CodeownersHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req dto.UploadCodeownersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	file, err := h.codeownersService.ForTenant(tenantID(r)).Upload(r.Context(), req.RepositoryID, req.Content)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.CodeownersResponse{RepositoryID: req.RepositoryID, Rules: file.Rules})
+}
+
+func (h *CodeownersHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	repositoryID := r.URL.Query().Get("repository_id")
+	if repositoryID == "" {
+		sendError(w, "repository_id is required", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	file, err := h.codeownersService.ForTenant(tenantID(r)).Get(r.Context(), repositoryID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.CodeownersResponse{RepositoryID: repositoryID, Rules: file.Rules})
+}
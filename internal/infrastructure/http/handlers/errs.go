@@ -2,18 +2,69 @@ package handlers
 
 import (
     "encoding/json"
+    "errors"
     "net/http"
 
+    "github.com/shmul/avito-task/internal/domain"
     "github.com/shmul/avito-task/internal/infrastructure/http/dto"
 )
 
+// kindStatus maps domain error kinds to HTTP statuses.
+var kindStatus = []struct {
+    kind   error
+    status int
+}{
+    {domain.ErrNotFound, http.StatusNotFound},
+    {domain.ErrConflict, http.StatusConflict},
+    {domain.ErrInvalidInput, http.StatusBadRequest},
+    {domain.ErrForbidden, http.StatusForbidden},
+    {domain.ErrPreconditionFailed, http.StatusPreconditionFailed},
+}
+
+// codedError is implemented by domain errors that carry an API error code.
+type codedError interface {
+    error
+    ErrorCode() string
+}
+
+// detailedError is implemented by domain errors that carry structured
+// details for the client.
+type detailedError interface {
+    ErrorDetails() any
+}
+
 func sendError(w http.ResponseWriter, message, code string, statusCode int) {
+    sendErrorDetails(w, dto.ErrorDetails{Code: code, Message: message}, statusCode)
+}
+
+func sendErrorDetails(w http.ResponseWriter, details dto.ErrorDetails, statusCode int) {
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(statusCode)
-    json.NewEncoder(w).Encode(dto.ErrorResponse{
-        Error: dto.ErrorDetails{
-            Code:    code,
-            Message: message,
-        },
-    })
-}
\ No newline at end of file
+    json.NewEncoder(w).Encode(dto.ErrorResponse{Error: details})
+}
+
+// writeError turns an error returned by a service into an ErrorResponse.
+// Errors that are not domain errors are reported as INTERNAL_ERROR.
+func writeError(w http.ResponseWriter, err error) {
+    var coded codedError
+    if !errors.As(err, &coded) {
+        sendError(w, err.Error(), "INTERNAL_ERROR", http.StatusInternalServerError)
+        return
+    }
+
+    status := http.StatusInternalServerError
+    for _, ks := range kindStatus {
+        if errors.Is(coded, ks.kind) {
+            status = ks.status
+            break
+        }
+    }
+
+    details := dto.ErrorDetails{Code: coded.ErrorCode(), Message: coded.Error()}
+    var detailed detailedError
+    if errors.As(err, &detailed) {
+        details.Details = detailed.ErrorDetails()
+    }
+
+    sendErrorDetails(w, details, status)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+)
+
+// setETag exposes pr's version as a strong entity tag, which clients echo
+// back in If-Match.
+func setETag(w http.ResponseWriter, pr *entity.PullRequest) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(pr.Version, 10)))
+}
+
+// ifMatch returns the PR versions listed in the request's If-Match header.
+// It is nil when the header is absent or "*". Weak and malformed tags never
+// match, so a header made only of those yields an empty, non-nil list.
+func ifMatch(r *http.Request) []int64 {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	versions := []int64{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
@@ -1,9 +1,10 @@
 package handlers
 
 import (
+    "context"
     "encoding/json"
-    "fmt"
     "net/http"
+    "github.com/shmul/avito-task/internal/domain/entity"
     "github.com/shmul/avito-task/internal/domain/service"
     "github.com/shmul/avito-task/internal/infrastructure/http/dto"
 )
@@ -29,21 +30,27 @@ func (h *PRHandler) CreatePR(w http.ResponseWriter, r *http.Request) {
         sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
         return
     }
+    if !mayActAs(r, req.AuthorID) {
+        sendError(w, "cannot create PRs on behalf of "+req.AuthorID, "FORBIDDEN", http.StatusForbidden)
+        return
+    }
 
-    pr, err := h.prService.CreatePR(req.PullRequestID, req.PullRequestName, req.AuthorID)
+    pr, err := h.prService.ForTenant(tenantID(r)).CreatePR(r.Context(), service.CreatePRInput{
+        PullRequestID:   req.PullRequestID,
+        PullRequestName: req.PullRequestName,
+        AuthorID:        req.AuthorID,
+        Draft:           req.Draft,
+        RepositoryID:    req.RepositoryID,
+        ChangedFiles:    req.ChangedFiles,
+        ActorID:         actorID(r),
+    })
     if err != nil {
-        switch err.Error() {
-        case fmt.Sprintf("PR already exists: %s", req.PullRequestID):
-            sendError(w, "PR id already exists", "PR_EXISTS", http.StatusConflict)
-        case fmt.Sprintf("author not found: %s", req.AuthorID):
-            sendError(w, "resource not found", "NOT_FOUND", http.StatusNotFound)
-        default:
-            sendError(w, err.Error(), "INTERNAL_ERROR", http.StatusInternalServerError)
-        }
+        writeError(w, err)
         return
     }
 
     w.Header().Set("Content-Type", "application/json")
+    setETag(w, pr)
     w.WriteHeader(http.StatusCreated)
     json.NewEncoder(w).Encode(dto.PRResponse{PR: pr})
 }
@@ -60,17 +67,14 @@ func (h *PRHandler) MergePR(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    pr, err := h.prService.MergePR(req.PullRequestID)
+    pr, err := h.scoped(r).MergePR(r.Context(), req.PullRequestID, req.Force, principal(r))
     if err != nil {
-        if err.Error() == fmt.Sprintf("PR not found: %s", req.PullRequestID) {
-            sendError(w, "resource not found", "NOT_FOUND", http.StatusNotFound)
-            return
-        }
-        sendError(w, err.Error(), "INTERNAL_ERROR", http.StatusInternalServerError)
+        writeError(w, err)
         return
     }
 
     w.Header().Set("Content-Type", "application/json")
+    setETag(w, pr)
     json.NewEncoder(w).Encode(dto.PRResponse{PR: pr})
 }
 
@@ -86,28 +90,140 @@ func (h *PRHandler) ReassignReviewer(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    result, err := h.prService.ReassignReviewer(req.PullRequestID, req.OldUserID)
+    result, err := h.scoped(r).ReassignReviewer(r.Context(), req.PullRequestID, req.OldUserID, actorID(r))
     if err != nil {
-        switch err.Error() {
-        case "cannot reassign on merged PR":
-            sendError(w, "cannot reassign on merged PR", "PR_MERGED", http.StatusConflict)
-        case "reviewer is not assigned to this PR":
-            sendError(w, "reviewer is not assigned to this PR", "NOT_ASSIGNED", http.StatusConflict)
-        case "no active replacement candidate in team":
-            sendError(w, "no active replacement candidate in team", "NO_CANDIDATE", http.StatusConflict)
-        case fmt.Sprintf("PR not found: %s", req.PullRequestID):
-            sendError(w, "resource not found", "NOT_FOUND", http.StatusNotFound)
-        case fmt.Sprintf("reviewer not found: %s", req.OldUserID):
-            sendError(w, "resource not found", "NOT_FOUND", http.StatusNotFound)
-        default:
-            sendError(w, err.Error(), "INTERNAL_ERROR", http.StatusInternalServerError)
-        }
+        writeError(w, err)
         return
     }
 
     w.Header().Set("Content-Type", "application/json")
+    setETag(w, result.PR)
     json.NewEncoder(w).Encode(dto.ReassignResponse{
         PR:         result.PR,
         ReplacedBy: result.ReplacedBy,
     })
+}
+
+func (h *PRHandler) ReviewPR(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req dto.ReviewPRRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+        return
+    }
+    // reviews count towards the merge policy, so they come from a known
+    // user even when an admin submits one for someone else
+    if actorID(r) == "" {
+        sendError(w, "reviews require credentials tied to a user", "FORBIDDEN", http.StatusForbidden)
+        return
+    }
+    if !mayActAs(r, req.ReviewerID) {
+        sendError(w, "cannot review on behalf of "+req.ReviewerID, "FORBIDDEN", http.StatusForbidden)
+        return
+    }
+
+    pr, err := h.scoped(r).ReviewPR(r.Context(), req.PullRequestID, req.ReviewerID, entity.ReviewState(req.State))
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    setETag(w, pr)
+    json.NewEncoder(w).Encode(dto.PRResponse{PR: pr})
+}
+
+func (h *PRHandler) ClosePR(w http.ResponseWriter, r *http.Request) {
+    h.changeStatus(w, r, h.scoped(r).ClosePR)
+}
+
+func (h *PRHandler) ReopenPR(w http.ResponseWriter, r *http.Request) {
+    h.changeStatus(w, r, h.scoped(r).ReopenPR)
+}
+
+func (h *PRHandler) MarkReady(w http.ResponseWriter, r *http.Request) {
+    h.changeStatus(w, r, h.scoped(r).MarkReady)
+}
+
+func (h *PRHandler) GetPR(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    prID := r.URL.Query().Get("pull_request_id")
+    if prID == "" {
+        sendError(w, "pull_request_id is required", "BAD_REQUEST", http.StatusBadRequest)
+        return
+    }
+
+    pr, err := h.prService.ForTenant(tenantID(r)).GetPR(r.Context(), prID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    setETag(w, pr)
+    json.NewEncoder(w).Encode(dto.PRResponse{PR: pr})
+}
+
+func (h *PRHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    prID := r.URL.Query().Get("pull_request_id")
+    if prID == "" {
+        sendError(w, "pull_request_id is required", "BAD_REQUEST", http.StatusBadRequest)
+        return
+    }
+
+    events, err := h.prService.ForTenant(tenantID(r)).GetHistory(r.Context(), prID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    response := dto.PRHistoryResponse{
+        PullRequestID: prID,
+        Events:        events,
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+func (h *PRHandler) changeStatus(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, prID, actorID string) (*entity.PullRequest, error)) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req dto.ChangePRStatusRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+        return
+    }
+
+    pr, err := change(r.Context(), req.PullRequestID, actorID(r))
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    setETag(w, pr)
+    json.NewEncoder(w).Encode(dto.PRResponse{PR: pr})
+}
+
+// scoped narrows the PR service to the request's tenant and its If-Match
+// precondition.
+func (h *PRHandler) scoped(r *http.Request) *service.PRService {
+    return h.prService.ForTenant(tenantID(r)).IfMatch(ifMatch(r))
 }
\ No newline at end of file
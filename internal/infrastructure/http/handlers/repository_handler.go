@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/http/dto"
+)
+
+type RepositoryHandler struct {
+	repositoryService *service.RepositoryService
+}
+
+func NewRepositoryHandler(repositoryService *service.RepositoryService) *RepositoryHandler {
+	return &RepositoryHandler{
+		repositoryService: repositoryService,
+	}
+}
+
+func (h *RepositoryHandler) SaveRepository(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req dto.SaveRepositoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	repository := &entity.Repository{
+		RepositoryID:  req.RepositoryID,
+		ReviewerCount: req.ReviewerCount,
+		Strategy:      req.Strategy,
+		EligibleTeams: req.EligibleTeams,
+		MergePolicy:   req.MergePolicy,
+	}
+	if err := h.repositoryService.ForTenant(tenantID(r)).SaveRepository(r.Context(), repository); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.RepositoryResponse{Repository: repository})
+}
+
+func (h *RepositoryHandler) GetRepository(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	repositoryID := r.URL.Query().Get("repository_id")
+	if repositoryID == "" {
+		sendError(w, "repository_id is required", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	repository, err := h.repositoryService.ForTenant(tenantID(r)).GetRepository(r.Context(), repositoryID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.RepositoryResponse{Repository: repository})
+}
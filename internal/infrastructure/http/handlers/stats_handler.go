@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/http/dto"
+)
+
+type StatsHandler struct {
+	statsService   *service.StatsService
+	metricsService *service.MetricsService
+}
+
+func NewStatsHandler(statsService *service.StatsService, metricsService *service.MetricsService) *StatsHandler {
+	return &StatsHandler{
+		statsService:   statsService,
+		metricsService: metricsService,
+	}
+}
+
+func (h *StatsHandler) GetReviewerStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, ok := statsFilter(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.statsService.ForTenant(tenantID(r)).GetReviewerStats(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.ReviewerStatsResponse{Reviewers: stats})
+}
+
+func (h *StatsHandler) GetTeamStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, ok := statsFilter(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := h.statsService.ForTenant(tenantID(r)).GetTeamStats(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.TeamStatsResponse{Teams: stats})
+}
+
+func (h *StatsHandler) GetReviewMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, ok := statsFilter(w, r)
+	if !ok {
+		return
+	}
+
+	metrics, err := h.metricsService.ForTenant(tenantID(r)).GetReviewMetrics(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
+
+// statsFilter reads the from, to and team query parameters. It writes a 400
+// and reports false when a time is malformed.
+func statsFilter(w http.ResponseWriter, r *http.Request) (entity.StatsFilter, bool) {
+	query := r.URL.Query()
+	filter := entity.StatsFilter{TeamName: query.Get("team")}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		t, err := parseTime(query.Get(name))
+		if err != nil {
+			sendError(w, name+" must be an RFC 3339 time or a YYYY-MM-DD date", "BAD_REQUEST", http.StatusBadRequest)
+			return filter, false
+		}
+		*dst = t
+	}
+
+	return filter, true
+}
+
+// parseTime accepts an RFC 3339 time or a date, which means midnight UTC.
+// An empty value yields nil.
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
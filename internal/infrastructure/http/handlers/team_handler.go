@@ -2,7 +2,6 @@ package handlers
 
 import (
     "encoding/json"
-    "fmt"
     "net/http"
     "github.com/shmul/avito-task/internal/domain/entity"
     "github.com/shmul/avito-task/internal/domain/service"
@@ -33,26 +32,24 @@ func (h *TeamHandler) AddTeam(w http.ResponseWriter, r *http.Request) {
 
     // Convert DTO to domain entity
     team := &entity.Team{
-        TeamName: req.TeamName,
-        Members:  make([]entity.User, len(req.Members)),
+        TeamName:      req.TeamName,
+        Members:       make([]entity.User, len(req.Members)),
+        FallbackTeams: req.FallbackTeams,
     }
 
     for i, member := range req.Members {
         team.Members[i] = entity.User{
-            UserID:   member.UserID,
-            Username: member.Username,
-            TeamName: req.TeamName,
-            IsActive: member.IsActive,
+            UserID:         member.UserID,
+            Username:       member.Username,
+            TeamName:       req.TeamName,
+            IsActive:       member.IsActive,
+            MaxOpenReviews: member.MaxOpenReviews,
         }
     }
 
     // Create team
-    if err := h.teamService.CreateTeam(team); err != nil {
-        if err.Error() == fmt.Sprintf("team already exists: %s", req.TeamName) {
-            sendError(w, "team_name already exists", "TEAM_EXISTS", http.StatusBadRequest)
-            return
-        }
-        sendError(w, err.Error(), "INTERNAL_ERROR", http.StatusInternalServerError)
+    if err := h.teamService.ForTenant(tenantID(r)).CreateTeam(r.Context(), team); err != nil {
+        writeError(w, err)
         return
     }
 
@@ -73,16 +70,34 @@ func (h *TeamHandler) GetTeam(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    team, err := h.teamService.GetTeam(teamName)
+    team, err := h.teamService.ForTenant(tenantID(r)).GetTeam(r.Context(), teamName)
     if err != nil {
-        if err.Error() == fmt.Sprintf("team not found: %s", teamName) {
-            sendError(w, "resource not found", "NOT_FOUND", http.StatusNotFound)
-            return
-        }
-        sendError(w, err.Error(), "INTERNAL_ERROR", http.StatusInternalServerError)
+        writeError(w, err)
         return
     }
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(team)
+}
+
+func (h *TeamHandler) SetFallbacks(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req dto.SetFallbacksRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+        return
+    }
+
+    team, err := h.teamService.ForTenant(tenantID(r)).SetFallbacks(r.Context(), req.TeamName, req.FallbackTeams)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(dto.TeamResponse{Team: team})
 }
\ No newline at end of file
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/shmul/avito-task/internal/infrastructure/http/middleware"
+)
+
+// tenantID returns the tenant the request was resolved to by
+// middleware.Tenant. Services are scoped to it before every call.
+func tenantID(r *http.Request) string {
+	return middleware.TenantID(r.Context())
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
     "encoding/json"
-    "fmt"
     "net/http"
     "github.com/shmul/avito-task/internal/domain/service"
     "github.com/shmul/avito-task/internal/infrastructure/http/dto"
@@ -32,18 +31,20 @@ func (h *UserHandler) SetUserActive(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    user, err := h.userService.SetUserActive(req.UserID, req.IsActive)
+    result, err := h.userService.ForTenant(tenantID(r)).As(principal(r)).SetUserActive(r.Context(), req.UserID, req.IsActive)
     if err != nil {
-        if err.Error() == fmt.Sprintf("user not found: %s", req.UserID) {
-            sendError(w, "resource not found", "NOT_FOUND", http.StatusNotFound)
-            return
-        }
-        sendError(w, err.Error(), "INTERNAL_ERROR", http.StatusInternalServerError)
+        writeError(w, err)
         return
     }
 
+    response := dto.UserResponse{
+        User:         result.User,
+        Reassigned:   toReassignments(result.Reassigned),
+        Unassignable: result.Unassignable,
+    }
+
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(dto.UserResponse{User: user})
+    json.NewEncoder(w).Encode(response)
 }
 
 func (h *UserHandler) GetUserReview(w http.ResponseWriter, r *http.Request) {
@@ -58,9 +59,9 @@ func (h *UserHandler) GetUserReview(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    prs, err := h.prService.GetPRsByReviewer(userID)
+    prs, err := h.prService.ForTenant(tenantID(r)).GetPRsByReviewer(r.Context(), userID)
     if err != nil {
-        sendError(w, err.Error(), "INTERNAL_ERROR", http.StatusInternalServerError)
+        writeError(w, err)
         return
     }
 
@@ -71,4 +72,48 @@ func (h *UserHandler) GetUserReview(w http.ResponseWriter, r *http.Request) {
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(response)
+}
+
+func (h *UserHandler) DeactivateUsers(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req dto.DeactivateUsersRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+        return
+    }
+    if req.TeamName == "" {
+        sendError(w, "team_name is required", "BAD_REQUEST", http.StatusBadRequest)
+        return
+    }
+
+    result, err := h.userService.ForTenant(tenantID(r)).As(principal(r)).DeactivateUsers(r.Context(), req.TeamName, req.UserIDs)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    response := dto.DeactivateUsersResponse{
+        Users:        result.Users,
+        Reassigned:   toReassignments(result.Reassigned),
+        Unassignable: result.Unassignable,
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+func toReassignments(reassigned []service.Reassignment) []dto.Reassignment {
+    result := make([]dto.Reassignment, 0, len(reassigned))
+    for _, reassignment := range reassigned {
+        result = append(result, dto.Reassignment{
+            PullRequestID: reassignment.PullRequestID,
+            OldReviewerID: reassignment.OldReviewerID,
+            ReplacedBy:    reassignment.ReplacedBy,
+        })
+    }
+    return result
 }
\ No newline at end of file
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/http/dto"
+	"github.com/shmul/avito-task/internal/infrastructure/http/middleware"
+	"github.com/shmul/avito-task/internal/infrastructure/vcs"
+)
+
+// maxWebhookBody caps provider payloads; PR events are far smaller than the
+// providers' own limits.
+const maxWebhookBody = 5 << 20
+
+// VCSSecrets authenticate one tenant's provider webhooks. An empty secret
+// verifies no delivery from that provider.
+type VCSSecrets struct {
+	GitHub string
+	GitLab string
+}
+
+// VCSHandler ingests provider webhooks. Deliveries carry no api key, so the
+// tenant is the one whose secret verifies the delivery, keyed by tenant in
+// secrets.
+type VCSHandler struct {
+	vcsService *service.VCSService
+	secrets    map[string]VCSSecrets
+}
+
+func NewVCSHandler(vcsService *service.VCSService, secrets map[string]VCSSecrets) *VCSHandler {
+	return &VCSHandler{
+		vcsService: vcsService,
+		secrets:    secrets,
+	}
+}
+
+func (h *VCSHandler) GitHub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	signature := r.Header.Get(vcs.GitHubSignatureHeader)
+	tenant, ok := h.tenantFor(w, r, func(secrets VCSSecrets) bool {
+		return vcs.VerifyGitHub(secrets.GitHub, body, signature)
+	})
+	if !ok {
+		return
+	}
+
+	if r.Header.Get(vcs.GitHubEventHeader) != "pull_request" {
+		h.writeIgnored(w)
+		return
+	}
+
+	event, err := vcs.ParseGitHub(body)
+	if err != nil {
+		sendError(w, err.Error(), "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	h.apply(w, r, tenant, event)
+}
+
+func (h *VCSHandler) GitLab(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(vcs.GitLabTokenHeader)
+	tenant, ok := h.tenantFor(w, r, func(secrets VCSSecrets) bool {
+		return vcs.VerifyGitLab(secrets.GitLab, token)
+	})
+	if !ok {
+		return
+	}
+
+	if r.Header.Get(vcs.GitLabEventHeader) != vcs.GitLabMergeRequestHook {
+		h.writeIgnored(w)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	event, err := vcs.ParseGitLab(body)
+	if err != nil {
+		sendError(w, err.Error(), "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	h.apply(w, r, tenant, event)
+}
+
+func (h *VCSHandler) LinkAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req dto.LinkVCSAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.vcsService.ForTenant(tenantID(r)).LinkAccount(r.Context(), req.Provider, req.Login, req.UserID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// tenantFor finds the one tenant whose secrets verify the delivery. It writes
+// an error and reports false when no tenant's do, or when the request names a
+// different tenant than the one its secret belongs to.
+func (h *VCSHandler) tenantFor(w http.ResponseWriter, r *http.Request, verify func(VCSSecrets) bool) (string, bool) {
+	var tenant string
+	for _, candidate := range slices.Sorted(maps.Keys(h.secrets)) {
+		if verify(h.secrets[candidate]) {
+			tenant = candidate
+			break
+		}
+	}
+	if tenant == "" {
+		sendError(w, "invalid signature or token", "UNAUTHORIZED", http.StatusUnauthorized)
+		return "", false
+	}
+
+	for _, requested := range []string{r.Header.Get(middleware.TenantHeader), r.URL.Query().Get("tenant")} {
+		if requested != "" && requested != tenant {
+			sendError(w, "webhook secret does not belong to tenant "+requested, "FORBIDDEN", http.StatusForbidden)
+			return "", false
+		}
+	}
+	return tenant, true
+}
+
+func (h *VCSHandler) apply(w http.ResponseWriter, r *http.Request, tenant string, event *service.VCSEvent) {
+	if event == nil {
+		h.writeIgnored(w)
+		return
+	}
+
+	pr, err := h.vcsService.ForTenant(tenant).Apply(r.Context(), event)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response := dto.VCSEventResponse{
+		PullRequestID: event.PullRequestID(),
+		Action:        string(event.Action),
+		PR:            pr,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *VCSHandler) writeIgnored(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.VCSEventResponse{Ignored: true})
+}
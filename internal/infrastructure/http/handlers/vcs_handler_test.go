@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/http/middleware"
+	"github.com/shmul/avito-task/internal/infrastructure/vcs"
+)
+
+// tenantLog records the tenants the fake repositories were read in. They
+// hold no data, so every delivery ends in NOT_FOUND once it is applied.
+type tenantLog struct {
+	tenants []string
+}
+
+type prRepo struct {
+	repo.PRRepository
+	log    *tenantLog
+	tenant string
+}
+
+func (r *prRepo) ForTenant(tenantID string) repo.PRRepository {
+	return &prRepo{log: r.log, tenant: tenantID}
+}
+
+func (r *prRepo) GetByID(_ context.Context, prID string) (*entity.PullRequest, error) {
+	r.log.tenants = append(r.log.tenants, r.tenant)
+	return nil, domain.NotFound("PR %s not found", prID)
+}
+
+type userRepo struct{ repo.UserRepository }
+
+func (r userRepo) ForTenant(string) repo.UserRepository { return r }
+
+func (userRepo) GetByVCSLogin(_ context.Context, provider, login string) (*entity.User, error) {
+	return nil, domain.NotFound("no user linked to %s login %s", provider, login)
+}
+
+type teamRepo struct{ repo.TeamRepository }
+
+func (r teamRepo) ForTenant(string) repo.TeamRepository { return r }
+
+type codeownersRepo struct{ repo.CodeownersRepository }
+
+func (r codeownersRepo) ForTenant(string) repo.CodeownersRepository { return r }
+
+type repositoryRepo struct{ repo.RepositoryRepository }
+
+func (r repositoryRepo) ForTenant(string) repo.RepositoryRepository { return r }
+
+type transactor struct{ prs *prRepo }
+
+func (t transactor) ForTenant(tenantID string) repo.Transactor {
+	return transactor{prs: t.prs.ForTenant(tenantID).(*prRepo)}
+}
+
+func (t transactor) WithinTx(_ context.Context, fn func(r repo.Repositories) error) error {
+	return fn(repo.Repositories{PRs: t.prs, Users: userRepo{}, Teams: teamRepo{}})
+}
+
+func TestVCSWebhookTenant(t *testing.T) {
+	body := `{"action":"opened","number":1,"pull_request":{"number":1,"title":"x","user":{"login":"octocat"}},"repository":{"full_name":"acme/widgets"},"sender":{"login":"octocat"}}`
+	sign := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	secrets := map[string]VCSSecrets{
+		"a": {GitHub: "secret-a", GitLab: "token-a"},
+		"b": {GitHub: "secret-b", GitLab: "token-b"},
+	}
+
+	tests := []struct {
+		name        string
+		gitlab      bool
+		credential  string
+		header      string
+		query       string
+		wantStatus  int
+		wantTenants []string
+	}{
+		{name: "github signed for a", credential: sign("secret-a"), wantStatus: http.StatusNotFound, wantTenants: []string{"a"}},
+		{name: "github signed for b", credential: sign("secret-b"), wantStatus: http.StatusNotFound, wantTenants: []string{"b"}},
+		{name: "github signed for a naming b in the header", credential: sign("secret-a"), header: "b", wantStatus: http.StatusForbidden},
+		{name: "github signed for a naming b in the query", credential: sign("secret-a"), query: "b", wantStatus: http.StatusForbidden},
+		{name: "github signed for a naming a", credential: sign("secret-a"), query: "a", wantStatus: http.StatusNotFound, wantTenants: []string{"a"}},
+		{name: "github signed with an unknown secret", credential: sign("other"), query: "a", wantStatus: http.StatusUnauthorized},
+		{name: "github unsigned", wantStatus: http.StatusUnauthorized},
+		{name: "gitlab token of a", gitlab: true, credential: "token-a", wantStatus: http.StatusNotFound, wantTenants: []string{"a"}},
+		{name: "gitlab token of a naming b", gitlab: true, credential: "token-a", header: "b", wantStatus: http.StatusForbidden},
+		{name: "gitlab github secret as a token", gitlab: true, credential: "secret-a", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &tenantLog{}
+			prRepo := &prRepo{log: log}
+			prService, err := service.NewPRService(prRepo, userRepo{}, teamRepo{}, codeownersRepo{}, repositoryRepo{}, transactor{prRepo}, &service.PRServiceConfig{ReviewerCount: 2}, slog.New(slog.DiscardHandler))
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := NewVCSHandler(service.NewVCSService(prService, prRepo, userRepo{}), secrets)
+
+			target := "/webhooks/github"
+			payload := body
+			if tt.gitlab {
+				target = "/webhooks/gitlab"
+				payload = `{"object_kind":"merge_request","user":{"id":1,"username":"alice"},"project":{"path_with_namespace":"acme/widgets"},"object_attributes":{"iid":1,"author_id":1,"title":"x","action":"open"}}`
+			}
+			if tt.query != "" {
+				target += "?tenant=" + tt.query
+			}
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(payload))
+			if tt.header != "" {
+				req.Header.Set(middleware.TenantHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			if tt.gitlab {
+				req.Header.Set(vcs.GitLabEventHeader, vcs.GitLabMergeRequestHook)
+				req.Header.Set(vcs.GitLabTokenHeader, tt.credential)
+				h.GitLab(rec, req)
+			} else {
+				req.Header.Set(vcs.GitHubEventHeader, "pull_request")
+				req.Header.Set(vcs.GitHubSignatureHeader, tt.credential)
+				h.GitHub(rec, req)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !slices.Equal(log.tenants, tt.wantTenants) {
+				t.Fatalf("read tenants %v, want %v", log.tenants, tt.wantTenants)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/service"
+)
+
+// Authenticator resolves the bearer credential of a request, an API key or
+// a JWT, to its principal.
+type Authenticator interface {
+	Authenticate(ctx context.Context, rawKey string) (*entity.Principal, error)
+}
+
+type principalKey struct{}
+
+// Auth authenticates the "Authorization: Bearer <credential>" header and
+// stores the principal in the request context. Without required, requests
+// that carry no credential at all may still read defaultTenant's data as
+// an anonymous read-only principal; they cannot change anything, cannot
+// pick another tenant, and with no default tenant they are rejected. A
+// credential that is sent is always checked.
+func Auth(authenticator Authenticator, required bool, defaultTenant string, log *slog.Logger) func(http.Handler) http.Handler {
+	anonymous := &entity.Principal{TenantID: defaultTenant, Role: entity.RoleReadOnly}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rawKey, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || rawKey == "" {
+				if required || defaultTenant == "" {
+					sendError(w, "missing credentials", "UNAUTHORIZED", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), anonymous)))
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r.Context(), rawKey)
+			if errors.Is(err, service.ErrInvalidCredentials) {
+				sendError(w, "invalid credentials", "UNAUTHORIZED", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				log.Error("failed to authenticate request",
+					slog.String("error", err.Error()),
+					slog.String("path", r.URL.Path),
+				)
+				sendError(w, "failed to authenticate", "INTERNAL_ERROR", http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// RequireRole rejects requests whose principal is below role.
+func RequireRole(role entity.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := PrincipalFrom(r.Context())
+			if principal == nil {
+				sendError(w, "missing credentials", "UNAUTHORIZED", http.StatusUnauthorized)
+				return
+			}
+			if !principal.Role.Allows(role) {
+				sendError(w, "requires role "+string(role), "FORBIDDEN", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithPrincipal returns a copy of ctx carrying principal.
+func WithPrincipal(ctx context.Context, principal *entity.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal stored in ctx by Auth, or nil.
+func PrincipalFrom(ctx context.Context) *entity.Principal {
+	principal, _ := ctx.Value(principalKey{}).(*entity.Principal)
+	return principal
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/service"
+)
+
+type keys map[string]*entity.Principal
+
+func (k keys) Authenticate(_ context.Context, rawKey string) (*entity.Principal, error) {
+	if principal, ok := k[rawKey]; ok {
+		return principal, nil
+	}
+	return nil, service.ErrInvalidCredentials
+}
+
+func TestAuthTenant(t *testing.T) {
+	authenticator := keys{
+		"any-tenant": {Role: entity.RoleAdmin},
+		"acme":       {TenantID: "acme", Role: entity.RoleMember},
+	}
+
+	tests := []struct {
+		name          string
+		defaultTenant string
+		key           string
+		header        string
+		wantStatus    int
+		wantTenant    string
+	}{
+		{name: "anonymous works on the default tenant", defaultTenant: "default", wantStatus: http.StatusOK, wantTenant: "default"},
+		{name: "anonymous cannot pick a tenant", defaultTenant: "default", header: "acme", wantStatus: http.StatusForbidden},
+		{name: "anonymous naming the default tenant", defaultTenant: "default", header: "default", wantStatus: http.StatusOK, wantTenant: "default"},
+		{name: "anonymous without a default tenant", wantStatus: http.StatusUnauthorized},
+		{name: "tenant-bound key", defaultTenant: "default", key: "acme", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "tenant-bound key naming another tenant", defaultTenant: "default", key: "acme", header: "other", wantStatus: http.StatusForbidden},
+		{name: "unbound key picks a tenant", defaultTenant: "default", key: "any-tenant", header: "other", wantStatus: http.StatusOK, wantTenant: "other"},
+		{name: "unknown key", defaultTenant: "default", key: "nope", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotTenant = TenantID(r.Context())
+			})
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			handler := Auth(authenticator, false, tt.defaultTenant, log)(Tenant(tt.defaultTenant)(next))
+
+			req := httptest.NewRequest(http.MethodGet, "/team/get", nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			if tt.header != "" {
+				req.Header.Set(TenantHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if gotTenant != tt.wantTenant {
+				t.Fatalf("tenant = %q, want %q", gotTenant, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestAuthAnonymousIsReadOnly(t *testing.T) {
+	tests := []struct {
+		role       entity.Role
+		wantStatus int
+	}{
+		{role: entity.RoleReadOnly, wantStatus: http.StatusOK},
+		{role: entity.RoleMember, wantStatus: http.StatusForbidden},
+		{role: entity.RoleTeamLead, wantStatus: http.StatusForbidden},
+		{role: entity.RoleAdmin, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			handler := Auth(keys{}, false, "default", log)(RequireRole(tt.role)(next))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/keys/issue", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
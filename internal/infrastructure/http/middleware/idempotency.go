@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// Idempotency makes POSTs carrying an Idempotency-Key safe to retry. The
+// first request with a key runs and its response is stored for ttl; repeats
+// get that response replayed without running the handler again. A repeat
+// with another body, or for another route, is refused with 422, and one
+// arriving while the first still runs with 409. Keys are scoped to the
+// tenant and the client. Only responses a retry would get again are stored;
+// see replayable. Responses marked "Cache-Control: no-store", such as newly
+// issued keys and secrets, keep only their status and headers, and repeats
+// of them are refused with 409 rather than handing the secret out again.
+func Idempotency(idempotencyRepo repo.IdempotencyRepository, ttl time.Duration, log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				sendError(w, "idempotency key too long", "BAD_REQUEST", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes+1))
+			if err != nil {
+				sendError(w, "Invalid request body", "BAD_REQUEST", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxIdempotentRequestBytes {
+				sendError(w, "request body too large", "BAD_REQUEST", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := sha256.New()
+			hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+			hash.Write(body)
+			requestHash := hex.EncodeToString(hash.Sum(nil))
+
+			// a key is the client's own, two clients of a tenant can pick
+			// the same one
+			storedKey := client(r) + " " + key
+			keys := idempotencyRepo.ForTenant(TenantID(r.Context()))
+
+			stored, err := keys.Begin(r.Context(), storedKey, requestHash, ttl)
+			if err != nil {
+				log.Error("failed to claim idempotency key",
+					slog.String("error", err.Error()),
+					slog.String("path", r.URL.Path),
+				)
+				sendError(w, "failed to check idempotency key", "INTERNAL_ERROR", http.StatusInternalServerError)
+				return
+			}
+
+			if stored != nil {
+				switch {
+				case stored.RequestHash != requestHash:
+					sendError(w, "idempotency key was used for a different request", "IDEMPOTENCY_KEY_REUSED", http.StatusUnprocessableEntity)
+				case stored.StatusCode == 0:
+					sendError(w, "a request with this idempotency key is being processed", "REQUEST_IN_PROGRESS", http.StatusConflict)
+				case noStore(stored.Header):
+					sendError(w, "the request with this idempotency key succeeded, but its response holds a secret and is not replayed", "RESPONSE_NOT_STORED", http.StatusConflict)
+				default:
+					for name, values := range stored.Header {
+						w.Header()[name] = values
+					}
+					w.Header().Set(IdempotentReplayedHeader, "true")
+					w.WriteHeader(stored.StatusCode)
+					w.Write(stored.Body)
+				}
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			before := make(map[string]bool, len(w.Header()))
+			for name := range w.Header() {
+				before[name] = true
+			}
+
+			// the key has to be released even when the handler panics, and
+			// settled even when the client is gone, or it stays in progress
+			// until it expires
+			settle := context.WithoutCancel(r.Context())
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := keys.Release(settle, storedKey); err != nil {
+					log.Error("failed to release idempotency key", slog.String("error", err.Error()))
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+
+			if !replayable(rec.statusCode) {
+				return
+			}
+
+			// only what the handler set belongs to the response, CORS and
+			// rate limit headers are set again on replay
+			header := make(map[string][]string)
+			for name, values := range w.Header() {
+				if !before[name] {
+					header[name] = values
+				}
+			}
+			responseBody := rec.body.Bytes()
+			if noStore(header) {
+				responseBody = nil
+			}
+			if err := keys.Complete(settle, storedKey, rec.statusCode, header, responseBody); err != nil {
+				log.Error("failed to store idempotent response", slog.String("error", err.Error()))
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// replayable reports whether a response with status may be stored for
+// replay: successes and client errors that running the request again would
+// repeat. Server errors, and conflicts, failed preconditions and rate limits
+// that depend on the state at the time, are not stored, so a retry runs the
+// request afresh.
+func replayable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusPreconditionFailed,
+		http.StatusTooEarly, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 200 && status < 500
+}
+
+// noStore reports whether header forbids storing the response body.
+func noStore(header map[string][]string) bool {
+	for _, directive := range strings.Split(http.Header(header).Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder passes the response through and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.statusCode = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+// memoryKeys is an IdempotencyRepository for a single tenant.
+type memoryKeys struct {
+	mu       sync.Mutex
+	requests map[string]*entity.IdempotentRequest
+}
+
+func (m *memoryKeys) ForTenant(string) repo.IdempotencyRepository {
+	return m
+}
+
+func (m *memoryKeys) Begin(_ context.Context, key, requestHash string, _ time.Duration) (*entity.IdempotentRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.requests[key]; ok {
+		return stored, nil
+	}
+	m.requests[key] = &entity.IdempotentRequest{Key: key, RequestHash: requestHash}
+	return nil, nil
+}
+
+func (m *memoryKeys) Complete(_ context.Context, key string, statusCode int, header map[string][]string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.requests[key]
+	stored.StatusCode = statusCode
+	stored.Header = header
+	stored.Body = body
+	return nil
+}
+
+func (m *memoryKeys) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.requests, key)
+	return nil
+}
+
+func TestIdempotencyReplays(t *testing.T) {
+	tests := []struct {
+		status     int
+		wantReplay bool
+	}{
+		{status: http.StatusCreated, wantReplay: true},
+		{status: http.StatusOK, wantReplay: true},
+		{status: http.StatusBadRequest, wantReplay: true},
+		{status: http.StatusNotFound, wantReplay: true},
+		{status: http.StatusConflict},
+		{status: http.StatusPreconditionFailed},
+		{status: http.StatusTooManyRequests},
+		{status: http.StatusInternalServerError},
+		{status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			runs := 0
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, `{"run":%d}`, runs)
+			})
+			keys := &memoryKeys{requests: make(map[string]*entity.IdempotentRequest)}
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			handler := Idempotency(keys, time.Hour, log)(next)
+
+			var first, second *httptest.ResponseRecorder
+			for _, rec := range []**httptest.ResponseRecorder{&first, &second} {
+				req := httptest.NewRequest(http.MethodPost, "/pullRequest/create", strings.NewReader(`{"pull_request_id":"pr-1"}`))
+				req.Header.Set(IdempotencyKeyHeader, "k1")
+				*rec = httptest.NewRecorder()
+				handler.ServeHTTP(*rec, req)
+			}
+
+			if first.Code != tt.status || second.Code != tt.status {
+				t.Fatalf("statuses = %d, %d, want %d", first.Code, second.Code, tt.status)
+			}
+			replayed := second.Header().Get(IdempotentReplayedHeader) == "true"
+			if tt.wantReplay {
+				if !replayed || runs != 1 || second.Body.String() != first.Body.String() {
+					t.Fatalf("replayed = %v after %d runs, body %q, want a replay of %q", replayed, runs, second.Body, first.Body)
+				}
+				return
+			}
+			if replayed || runs != 2 {
+				t.Fatalf("replayed = %v after %d runs, want the request run again", replayed, runs)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeepsSecretsOut(t *testing.T) {
+	runs := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"api_key":"prk_secret%d"}`, runs)
+	})
+	keys := &memoryKeys{requests: make(map[string]*entity.IdempotentRequest)}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := Idempotency(keys, time.Hour, log)(next)
+
+	var first, second *httptest.ResponseRecorder
+	for _, rec := range []**httptest.ResponseRecorder{&first, &second} {
+		req := httptest.NewRequest(http.MethodPost, "/auth/keys/issue", strings.NewReader(`{"name":"ci"}`))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		*rec = httptest.NewRecorder()
+		handler.ServeHTTP(*rec, req)
+	}
+
+	if first.Code != http.StatusCreated || !strings.Contains(first.Body.String(), "prk_secret1") {
+		t.Fatalf("first = %d %q, want the issued key", first.Code, first.Body)
+	}
+	if len(keys.requests) != 1 {
+		t.Fatalf("stored %d keys, want 1", len(keys.requests))
+	}
+	for _, stored := range keys.requests {
+		if stored.StatusCode != http.StatusCreated || stored.Body != nil {
+			t.Fatalf("stored = %+v, want the status without the body", stored)
+		}
+	}
+	if second.Code != http.StatusConflict || strings.Contains(second.Body.String(), "prk_") || runs != 1 {
+		t.Fatalf("second = %d %q after %d runs, want 409 without the key and no second run", second.Code, second.Body, runs)
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shmul/avito-task/internal/infrastructure/ratelimit"
+)
+
+// RateLimit takes a token for every request and refuses it with 429 once
+// the client's bucket is empty. Clients are told apart by their API key or
+// user when the request is authenticated, and by IP otherwise. A failing
+// store lets requests through rather than take the API down with it.
+func RateLimit(limiter *ratelimit.Limiter, log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, limit, limited, err := limiter.Take(r.Context(), client(r), r.URL.Path)
+			if err != nil {
+				log.Error("failed to check rate limit",
+					slog.String("error", err.Error()),
+					slog.String("path", r.URL.Path),
+				)
+			}
+			if !limited || err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+				sendError(w, "rate limit exceeded", "RATE_LIMITED", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// client identifies who a request counts against.
+func client(r *http.Request) string {
+	if principal := PrincipalFrom(r.Context()); principal != nil {
+		if principal.KeyID != 0 {
+			return "key:" + strconv.FormatInt(principal.KeyID, 10)
+		}
+		if principal.UserID != "" {
+			return "user:" + principal.TenantID + "/" + principal.UserID
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
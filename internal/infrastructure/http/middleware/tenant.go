@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// TenantHeader selects the tenant a request works on.
+const TenantHeader = "X-Tenant-ID"
+
+const maxTenantLength = 255
+
+type tenantKey struct{}
+
+// Tenant resolves the tenant of every request and stores it in the request
+// context. A principal bound to a tenant always works on that tenant;
+// otherwise the header and then defaultTenant are used. Requests naming an invalid tenant, or none at all without a default,
+// are rejected.
+func Tenant(defaultTenant string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID := r.Header.Get(TenantHeader)
+			if principal := PrincipalFrom(r.Context()); principal != nil && principal.TenantID != "" {
+				if tenantID != "" && tenantID != principal.TenantID {
+					sendError(w, "credentials do not belong to tenant "+tenantID, "FORBIDDEN", http.StatusForbidden)
+					return
+				}
+				tenantID = principal.TenantID
+			}
+			if tenantID == "" {
+				tenantID = defaultTenant
+			}
+
+			if !validTenant(tenantID) {
+				sendError(w, "missing or invalid tenant", "BAD_REQUEST", http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), tenantID)))
+		})
+	}
+}
+
+// WithTenant returns a copy of ctx carrying tenantID.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantID returns the tenant stored in ctx by Tenant, or "" when there is
+// none. Repositories scoped to "" see no data.
+func TenantID(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
+
+// validTenant accepts IDs of letters, digits, '.', '_' and '-'.
+func validTenant(tenantID string) bool {
+	if tenantID == "" || len(tenantID) > maxTenantLength {
+		return false
+	}
+	for _, c := range tenantID {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.' || c == '_' || c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -3,47 +3,130 @@ package server
 import (
 	"log/slog"
 	"net/http"
+	"time"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
 	"github.com/shmul/avito-task/internal/domain/service"
 	"github.com/shmul/avito-task/internal/infrastructure/http/handlers"
 	"github.com/shmul/avito-task/internal/infrastructure/http/middleware"
+	"github.com/shmul/avito-task/internal/infrastructure/ratelimit"
 )
 
 type Router struct {
-	teamHandler *handlers.TeamHandler
-	userHandler *handlers.UserHandler
-	prHandler   *handlers.PRHandler
-	log         *slog.Logger
+	teamHandler       *handlers.TeamHandler
+	userHandler       *handlers.UserHandler
+	prHandler         *handlers.PRHandler
+	statsHandler      *handlers.StatsHandler
+	webhookHandler    *handlers.WebhookHandler
+	vcsHandler        *handlers.VCSHandler
+	codeownersHandler *handlers.CodeownersHandler
+	repositoryHandler *handlers.RepositoryHandler
+	authHandler       *handlers.AuthHandler
+	authService       *service.AuthService
+	authRequired      bool
+	limiter           *ratelimit.Limiter
+	idempotencyRepo   repo.IdempotencyRepository
+	idempotencyTTL    time.Duration
+	defaultTenant     string
+	log               *slog.Logger
 }
 
-func NewRouter(userService *service.UserService, teamService *service.TeamService, prService *service.PRService, log *slog.Logger) *Router {
+func NewRouter(userService *service.UserService, teamService *service.TeamService, prService *service.PRService, statsService *service.StatsService, metricsService *service.MetricsService, webhookService *service.WebhookService, vcsService *service.VCSService, vcsSecrets map[string]handlers.VCSSecrets, codeownersService *service.CodeownersService, repositoryService *service.RepositoryService, authService *service.AuthService, authRequired bool, limiter *ratelimit.Limiter, idempotencyRepo repo.IdempotencyRepository, idempotencyTTL time.Duration, defaultTenant string, log *slog.Logger) *Router {
 	return &Router{
-		teamHandler: handlers.NewTeamHandler(teamService),
-		userHandler: handlers.NewUserHandler(userService, prService),
-		prHandler:   handlers.NewPRHandler(prService),
-		log:         log,
+		teamHandler:       handlers.NewTeamHandler(teamService),
+		userHandler:       handlers.NewUserHandler(userService, prService),
+		prHandler:         handlers.NewPRHandler(prService),
+		statsHandler:      handlers.NewStatsHandler(statsService, metricsService),
+		webhookHandler:    handlers.NewWebhookHandler(webhookService),
+		vcsHandler:        handlers.NewVCSHandler(vcsService, vcsSecrets),
+		codeownersHandler: handlers.NewCodeownersHandler(codeownersService),
+		repositoryHandler: handlers.NewRepositoryHandler(repositoryService),
+		authHandler:       handlers.NewAuthHandler(authService),
+		authService:       authService,
+		authRequired:      authRequired,
+		limiter:           limiter,
+		idempotencyRepo:   idempotencyRepo,
+		idempotencyTTL:    idempotencyTTL,
+		defaultTenant:     defaultTenant,
+		log:               log,
 	}
 }
 
 func (r *Router) SetupRoutes() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/team/add", r.teamHandler.AddTeam)
-	mux.HandleFunc("/team/get", r.teamHandler.GetTeam)
+	// route registers handler for callers with at least role
+	route := func(pattern string, role entity.Role, handler http.HandlerFunc) {
+		mux.Handle(pattern, middleware.RequireRole(role)(handler))
+	}
+
+	route("/team/add", entity.RoleAdmin, r.teamHandler.AddTeam)
+	route("/team/get", entity.RoleReadOnly, r.teamHandler.GetTeam)
+	route("/team/setFallbacks", entity.RoleAdmin, r.teamHandler.SetFallbacks)
+	route("/team/deactivateUsers", entity.RoleTeamLead, r.userHandler.DeactivateUsers)
+
+	route("/users/setIsActive", entity.RoleTeamLead, r.userHandler.SetUserActive)
+	route("/users/getReview", entity.RoleReadOnly, r.userHandler.GetUserReview)
+	route("/users/linkVcsAccount", entity.RoleAdmin, r.vcsHandler.LinkAccount)
+
+	route("/pullRequest/create", entity.RoleMember, r.prHandler.CreatePR)
+	route("/pullRequest/merge", entity.RoleMember, r.prHandler.MergePR)
+	route("/pullRequest/close", entity.RoleMember, r.prHandler.ClosePR)
+	route("/pullRequest/reopen", entity.RoleMember, r.prHandler.ReopenPR)
+	route("/pullRequest/markReady", entity.RoleMember, r.prHandler.MarkReady)
+	route("/pullRequest/reassign", entity.RoleMember, r.prHandler.ReassignReviewer)
+	route("/pullRequest/review", entity.RoleMember, r.prHandler.ReviewPR)
+	route("/pullRequest/get", entity.RoleReadOnly, r.prHandler.GetPR)
+	route("/pullRequest/history", entity.RoleReadOnly, r.prHandler.GetHistory)
+
+	route("/repositories/save", entity.RoleAdmin, r.repositoryHandler.SaveRepository)
+	route("/repositories/get", entity.RoleReadOnly, r.repositoryHandler.GetRepository)
+
+	route("/codeowners/upload", entity.RoleAdmin, r.codeownersHandler.Upload)
+	route("/codeowners/get", entity.RoleReadOnly, r.codeownersHandler.Get)
 
-	mux.HandleFunc("/users/setIsActive", r.userHandler.SetUserActive)
-	mux.HandleFunc("/users/getReview", r.userHandler.GetUserReview)
+	route("/stats/reviewers", entity.RoleReadOnly, r.statsHandler.GetReviewerStats)
+	route("/stats/teams", entity.RoleReadOnly, r.statsHandler.GetTeamStats)
+	route("/stats/reviewLatency", entity.RoleReadOnly, r.statsHandler.GetReviewMetrics)
 
-	mux.HandleFunc("/pullRequest/create", r.prHandler.CreatePR)
-	mux.HandleFunc("/pullRequest/merge", r.prHandler.MergePR)
-	mux.HandleFunc("/pullRequest/reassign", r.prHandler.ReassignReviewer)
+	route("/webhooks/register", entity.RoleAdmin, r.webhookHandler.RegisterWebhook)
+	route("/webhooks/failedDeliveries", entity.RoleAdmin, r.webhookHandler.GetFailedDeliveries)
+
+	route("/auth/keys/issue", entity.RoleAdmin, r.authHandler.IssueKey)
+	route("/auth/keys/revoke", entity.RoleAdmin, r.authHandler.RevokeKey)
+
+	// rate limits are taken after authentication so they count against
+	// the caller's key rather than its IP; idempotency keys need the tenant
+	scoped := func(next http.Handler) http.Handler {
+		next = middleware.Idempotency(r.idempotencyRepo, r.idempotencyTTL, r.log)(next)
+		next = middleware.Tenant(r.defaultTenant)(next)
+		if r.limiter != nil {
+			next = middleware.RateLimit(r.limiter, r.log)(next)
+		}
+		return next
+	}
+
+	// provider webhooks carry their own signatures instead of an api key,
+	// and the secret that verifies one decides its tenant
+	limited := func(next http.Handler) http.Handler {
+		if r.limiter != nil {
+			next = middleware.RateLimit(r.limiter, r.log)(next)
+		}
+		return next
+	}
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// everything but the health check works on a tenant's data
+	root := http.NewServeMux()
+	root.Handle("/", middleware.Auth(r.authService, r.authRequired, r.defaultTenant, r.log)(scoped(mux)))
+	root.Handle("/webhooks/github", limited(http.HandlerFunc(r.vcsHandler.GitHub)))
+	root.Handle("/webhooks/gitlab", limited(http.HandlerFunc(r.vcsHandler.GitLab)))
+	root.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"status": "ok"}`))
 	})
 
 	//middleware
-	handler := middleware.CORS(mux)
+	handler := middleware.CORS(root)
 	handler = middleware.Logging(r.log)(handler)
 	handler = middleware.Recovery(r.log)(handler)
 
@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minRSABits rejects keys too short to trust.
+const minRSABits = 2048
+
+// maxDocument caps discovery and JWKS responses.
+const maxDocument = 1 << 20
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// errUnavailable marks failures to get the keys, as opposed to tokens that
+// fail verification.
+var errUnavailable = errors.New("jwks unavailable")
+
+// keySet caches the issuer's JWKS. It is refreshed every refresh interval,
+// and early when a token names a key it does not know, which is how issuers
+// rotate keys; early refreshes are throttled so forged kids cannot make it
+// hammer the issuer. Refreshes run outside the lock, one at a time: known
+// keys are served from the cache meanwhile and only callers needing a key
+// the cache lacks wait for them. A failed refresh keeps serving the last
+// good keys.
+type keySet struct {
+	client   *http.Client
+	issuer   string
+	refresh  time.Duration
+	throttle time.Duration
+
+	mu        sync.Mutex
+	jwksURL   string
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	// refreshing is closed once the refresh in flight, if any, finishes;
+	// fetchErr is how the last one ended.
+	refreshing chan struct{}
+	fetchErr   error
+}
+
+func (s *keySet) get(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	age := time.Since(s.fetchedAt)
+	key, ok := s.keys[kid]
+	if age >= s.refresh || (!ok && age >= s.throttle) {
+		s.startRefresh(ctx)
+	}
+	done := s.refreshing
+	s.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", errUnavailable, ctx.Err())
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	// the key may be one the issuer rotated in, which only a successful
+	// refresh can tell
+	if done != nil && s.fetchErr != nil {
+		return nil, fmt.Errorf("%w: %w", errUnavailable, s.fetchErr)
+	}
+	return nil, fmt.Errorf("%w: %s", errUnknownKey, kid)
+}
+
+// startRefresh fetches the keys in the background unless a fetch is
+// already running. Callers hold s.mu.
+func (s *keySet) startRefresh(ctx context.Context) {
+	if s.refreshing != nil {
+		return
+	}
+	// throttle failures as well, an unreachable issuer should not add a
+	// round trip to every request
+	s.fetchedAt = time.Now()
+	s.refreshing = make(chan struct{})
+	jwksURL := s.jwksURL
+
+	// the keys are shared, so a caller going away must not abort the
+	// refresh for everyone waiting on it; the client timeout bounds it
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		keys, jwksURL, err := s.fetch(ctx, jwksURL)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err == nil {
+			s.keys = keys
+			s.jwksURL = jwksURL
+		}
+		s.fetchErr = err
+		close(s.refreshing)
+		s.refreshing = nil
+	}()
+}
+
+// fetch gets the keys from jwksURL, discovering it from the issuer when it
+// is empty, and returns them with the URL they came from.
+func (s *keySet) fetch(ctx context.Context, jwksURL string) (map[string]crypto.PublicKey, string, error) {
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := s.getJSON(ctx, strings.TrimSuffix(s.issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, "", fmt.Errorf("failed to discover jwks: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, "", errors.New("failed to discover jwks: no jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := s.getJSON(ctx, jwksURL, &jwks); err != nil {
+		return nil, "", fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// skip what we cannot use instead of failing the whole set, issuers
+		// publish key types we do not support
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, jwksURL, nil
+}
+
+func (s *keySet) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxDocument)).Decode(v)
+}
+
+// jwk is a JSON Web Key; only the members of RSA and EC public keys are
+// read.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid e")
+		}
+
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key shorter than %d bits", minRSABits)
+		}
+		return key, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != 32 {
+			return nil, errors.New("invalid x")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != 32 {
+			return nil, errors.New("invalid y")
+		}
+
+		// checks the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
@@ -0,0 +1,211 @@
+// Package oidc verifies JWT bearer tokens issued by an OpenID Connect
+// provider against its published JWKS. Only RS256 and ES256 signatures are
+// accepted.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain/service"
+)
+
+type Config struct {
+	// Issuer must match the iss claim. The JWKS is discovered from it
+	// unless JWKSURL is set.
+	Issuer  string
+	JWKSURL string
+	// Audience must be listed in the aud claim. It is required: without
+	// it any token the issuer signs for another client would be accepted.
+	Audience string
+	// Refresh is how long fetched keys are trusted before being fetched
+	// again. Unknown kids trigger a refresh at most every RefetchThrottle.
+	Refresh         time.Duration
+	RefetchThrottle time.Duration
+	// Leeway absorbs clock skew when checking exp and nbf.
+	Leeway  time.Duration
+	Timeout time.Duration
+}
+
+// Verifier checks the signature and registered claims of JWTs.
+type Verifier struct {
+	keys     *keySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+func NewVerifier(cfg Config) (*Verifier, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("oidc issuer is required")
+	}
+	if cfg.Audience == "" {
+		return nil, errors.New("oidc audience is required")
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = time.Hour
+	}
+	if cfg.RefetchThrottle <= 0 {
+		cfg.RefetchThrottle = 30 * time.Second
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &Verifier{
+		keys: &keySet{
+			client:   &http.Client{Timeout: cfg.Timeout},
+			issuer:   cfg.Issuer,
+			jwksURL:  cfg.JWKSURL,
+			refresh:  cfg.Refresh,
+			throttle: cfg.RefetchThrottle,
+		},
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		leeway:   cfg.Leeway,
+	}, nil
+}
+
+// Verify returns the claims of a valid token. Tokens failing verification
+// get an error wrapping service.ErrInvalidCredentials; any other error means
+// the issuer's keys could not be fetched to check the token.
+func (v *Verifier) Verify(ctx context.Context, token string) (map[string]any, error) {
+	claims, err := v.verify(ctx, token)
+	if err != nil && !errors.Is(err, errUnavailable) {
+		return nil, fmt.Errorf("%w: %w", service.ErrInvalidCredentials, err)
+	}
+	return claims, err
+}
+
+func (v *Verifier) verify(ctx context.Context, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	key, err := v.keys.get(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(header.Alg, key, digest[:], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// verifySignature checks signature with key, which has to be of the type
+// alg requires so a key can never be used with another algorithm.
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) error {
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match alg RS256")
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key does not match alg ES256")
+		}
+		// JWS carries r and s as fixed size big-endian integers, not ASN.1
+		if len(signature) != 64 {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+
+	default:
+		return fmt.Errorf("unsupported alg: %s", alg)
+	}
+
+	return nil
+}
+
+func (v *Verifier) checkClaims(claims map[string]any) error {
+	if iss, _ := claims["iss"].(string); iss != v.issuer {
+		return fmt.Errorf("unexpected issuer: %s", iss)
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	if !slices.Contains(audiences, v.audience) {
+		return errors.New("token not issued for this audience")
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no exp")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.leeway)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,353 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain/service"
+)
+
+const audience = "pull-requester"
+
+// issuer serves discovery and a JWKS that tests can swap out or break.
+type issuer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	jwks    []map[string]string
+	down    bool
+	fetches int
+}
+
+func newIssuer(t *testing.T, keys ...signer) *issuer {
+	t.Helper()
+
+	iss := &issuer{}
+	iss.publish(keys...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": iss.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		iss.mu.Lock()
+		defer iss.mu.Unlock()
+		iss.fetches++
+		if iss.down {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": iss.jwks})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+
+	return iss
+}
+
+func (iss *issuer) publish(keys ...signer) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.jwks = nil
+	for _, k := range keys {
+		iss.jwks = append(iss.jwks, k.jwk())
+	}
+}
+
+func (iss *issuer) setDown(down bool) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.down = down
+}
+
+func (iss *issuer) fetchCount() int {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	return iss.fetches
+}
+
+func (iss *issuer) verifier(t *testing.T, refresh time.Duration) *Verifier {
+	t.Helper()
+	v, err := NewVerifier(Config{
+		Issuer:          iss.URL,
+		Audience:        audience,
+		Refresh:         refresh,
+		RefetchThrottle: time.Nanosecond,
+		Leeway:          time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewVerifier(): %v", err)
+	}
+	return v
+}
+
+// signer is a test key that publishes itself as a JWK and signs tokens.
+type signer struct {
+	kid string
+	alg string
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func rsaSigner(t *testing.T, kid string) signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer{kid: kid, alg: "RS256", rsa: key}
+}
+
+func ecSigner(t *testing.T, kid string) signer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer{kid: kid, alg: "ES256", ec: key}
+}
+
+func (k signer) jwk() map[string]string {
+	if k.rsa != nil {
+		return map[string]string{
+			"kid": k.kid,
+			"kty": "RSA",
+			"use": "sig",
+			"n":   b64(k.rsa.N.Bytes()),
+			"e":   b64(big.NewInt(int64(k.rsa.E)).Bytes()),
+		}
+	}
+	return map[string]string{
+		"kid": k.kid,
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   b64(k.ec.X.FillBytes(make([]byte, 32))),
+		"y":   b64(k.ec.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// sign issues a token with header alg and kid, signed with k's key.
+func (k signer) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	if k.rsa != nil {
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signingInput + "." + b64(signature)
+}
+
+func (k signer) token(t *testing.T, claims map[string]any) string {
+	return k.sign(t, k.alg, k.kid, claims)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func claimsFor(iss *issuer) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss": iss.URL,
+		"aud": audience,
+		"sub": "u1",
+		"exp": now.Add(time.Hour).Unix(),
+		"nbf": now.Add(-time.Minute).Unix(),
+	}
+}
+
+func with(claims map[string]any, key string, value any) map[string]any {
+	out := make(map[string]any, len(claims))
+	for k, v := range claims {
+		out[k] = v
+	}
+	if value == nil {
+		delete(out, key)
+	} else {
+		out[key] = value
+	}
+	return out
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey := rsaSigner(t, "rsa-1")
+	ecKey := ecSigner(t, "ec-1")
+	stranger := rsaSigner(t, "rsa-1")
+	iss := newIssuer(t, rsaKey, ecKey)
+	claims := claimsFor(iss)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "RS256", token: rsaKey.token(t, claims)},
+		{name: "ES256", token: ecKey.token(t, claims)},
+		{name: "audience in a list", token: rsaKey.token(t, with(claims, "aud", []string{"other", audience}))},
+		{name: "expired within leeway", token: rsaKey.token(t, with(claims, "exp", now.Add(-30*time.Second).Unix()))},
+		{name: "signed by another key", token: stranger.token(t, claims), wantErr: true},
+		{name: "tampered claims", token: tamper(rsaKey.token(t, claims)), wantErr: true},
+		{name: "RS256 header on an EC key", token: ecKey.sign(t, "RS256", "ec-1", claims), wantErr: true},
+		{name: "ES256 header on an RSA key", token: rsaKey.sign(t, "ES256", "rsa-1", claims), wantErr: true},
+		{name: "alg none", token: rsaKey.sign(t, "none", "rsa-1", claims), wantErr: true},
+		{name: "HS256", token: rsaKey.sign(t, "HS256", "rsa-1", claims), wantErr: true},
+		{name: "unknown kid", token: rsaKey.sign(t, "RS256", "rsa-2", claims), wantErr: true},
+		{name: "expired", token: rsaKey.token(t, with(claims, "exp", now.Add(-time.Hour).Unix())), wantErr: true},
+		{name: "no exp", token: rsaKey.token(t, with(claims, "exp", nil)), wantErr: true},
+		{name: "not valid yet", token: rsaKey.token(t, with(claims, "nbf", now.Add(time.Hour).Unix())), wantErr: true},
+		{name: "wrong audience", token: rsaKey.token(t, with(claims, "aud", "other")), wantErr: true},
+		{name: "no audience", token: rsaKey.token(t, with(claims, "aud", nil)), wantErr: true},
+		{name: "wrong issuer", token: rsaKey.token(t, with(claims, "iss", "https://evil.example")), wantErr: true},
+		{name: "malformed", token: "not.a-token", wantErr: true},
+	}
+
+	v := iss.verifier(t, time.Hour)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(context.Background(), tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, service.ErrInvalidCredentials) {
+					t.Fatalf("Verify() = %v, want invalid credentials", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify(): %v", err)
+			}
+			if got["sub"] != "u1" {
+				t.Fatalf("sub = %v, want u1", got["sub"])
+			}
+		})
+	}
+}
+
+func TestNewVerifierRequiresAudience(t *testing.T) {
+	for _, cfg := range []Config{
+		{Issuer: "https://issuer.example"},
+		{Audience: audience},
+	} {
+		if _, err := NewVerifier(cfg); err == nil {
+			t.Errorf("NewVerifier(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+// tamper swaps the claims of token for others, keeping its signature.
+func tamper(token string) string {
+	header, rest, _ := strings.Cut(token, ".")
+	_, signature, _ := strings.Cut(rest, ".")
+	payload, _ := json.Marshal(map[string]any{"sub": "admin"})
+	return header + "." + b64(payload) + "." + signature
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	oldKey := rsaSigner(t, "old")
+	newKey := ecSigner(t, "new")
+	iss := newIssuer(t, oldKey)
+	v := iss.verifier(t, time.Hour)
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, oldKey.token(t, claimsFor(iss))); err != nil {
+		t.Fatalf("old key: %v", err)
+	}
+
+	iss.publish(newKey)
+	if _, err := v.Verify(ctx, newKey.token(t, claimsFor(iss))); err != nil {
+		t.Fatalf("new key after rotation: %v", err)
+	}
+	if _, err := v.Verify(ctx, oldKey.token(t, claimsFor(iss))); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("retired key = %v, want invalid credentials", err)
+	}
+}
+
+func TestVerifyIssuerUnavailable(t *testing.T) {
+	key := rsaSigner(t, "rsa-1")
+	iss := newIssuer(t, key)
+	iss.setDown(true)
+	v := iss.verifier(t, time.Hour)
+
+	_, err := v.Verify(context.Background(), key.token(t, claimsFor(iss)))
+	if err == nil || errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("Verify() = %v, want an error that is not invalid credentials", err)
+	}
+}
+
+func TestVerifyServesCachedKeysWhileRefreshing(t *testing.T) {
+	key := rsaSigner(t, "rsa-1")
+	iss := newIssuer(t, key)
+	v := iss.verifier(t, time.Nanosecond)
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, key.token(t, claimsFor(iss))); err != nil {
+		t.Fatalf("first verify: %v", err)
+	}
+
+	// every call is now past the refresh interval; the refreshes fail in
+	// the background while the cached key keeps working
+	iss.setDown(true)
+	for range 10 {
+		if _, err := v.Verify(ctx, key.token(t, claimsFor(iss))); err != nil {
+			t.Fatalf("verify with issuer down: %v", err)
+		}
+	}
+
+	// a key the cache lacks cannot be vouched for while the issuer is down
+	other := rsaSigner(t, "rsa-2")
+	_, err := v.Verify(ctx, other.token(t, claimsFor(iss)))
+	if err == nil || errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("unknown kid with issuer down = %v, want an error that is not invalid credentials", err)
+	}
+}
+
+func TestVerifyConcurrentRefreshFetchesOnce(t *testing.T) {
+	key := rsaSigner(t, "rsa-1")
+	iss := newIssuer(t, key)
+	v := iss.verifier(t, time.Hour)
+	token := key.token(t, claimsFor(iss))
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if _, err := v.Verify(context.Background(), token); err != nil {
+				t.Errorf("Verify(): %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := iss.fetchCount(); n != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", n)
+	}
+}
@@ -0,0 +1,86 @@
+// Package ratelimit implements token-bucket rate limits per client and
+// route.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit refills a bucket at Rate tokens per second up to Burst tokens. A
+// zero Rate does not limit at all.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// Remaining is how many whole tokens are left.
+	Remaining int
+	// RetryAfter is how long until the next token; only set when the
+	// request was refused.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store keeps the buckets. Take removes one token from the bucket of key if
+// it has one.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Limiter picks the limit of a route and takes from the bucket of the
+// client for it. Routes without a limit of their own share one bucket per
+// client.
+type Limiter struct {
+	store  Store
+	limit  Limit
+	routes map[string]Limit
+}
+
+func NewLimiter(store Store, limit Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{
+		store:  store,
+		limit:  limit,
+		routes: routes,
+	}
+}
+
+// Take takes a token for client calling path. ok is false when path is not
+// limited.
+func (l *Limiter) Take(ctx context.Context, client, path string) (result Result, limit Limit, ok bool, err error) {
+	key := client + " *"
+	limit = l.limit
+	if routeLimit, found := l.routes[path]; found {
+		key = client + " " + path
+		limit = routeLimit
+	}
+	if limit.Rate <= 0 {
+		return Result{}, limit, false, nil
+	}
+
+	result, err = l.store.Take(ctx, key, limit)
+	return result, limit, true, err
+}
+
+// NewResult computes the Result of a bucket holding tokens after the refill,
+// allowed telling whether one of them was taken.
+func NewResult(tokens float64, allowed bool, limit Limit) Result {
+	r := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
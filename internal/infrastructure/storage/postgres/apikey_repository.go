@@ -0,0 +1,75 @@
+package postgres
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "github.com/shmul/avito-task/internal/domain"
+    "github.com/shmul/avito-task/internal/domain/entity"
+    "github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type APIKeyRepository struct {
+    db     dbtx
+    tenant string
+}
+
+func NewAPIKeyRepository(s *Storage) repo.APIKeyRepository {
+    return &APIKeyRepository{db: s.conn()}
+}
+
+func (r *APIKeyRepository) ForTenant(tenantID string) repo.APIKeyRepository {
+    return &APIKeyRepository{db: r.db, tenant: tenantID}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *entity.APIKey, keyHash string) error {
+    err := r.db.QueryRowContext(ctx, `
+        INSERT INTO api_keys (tenant_id, key_hash, name, role, user_id, team_name)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))
+        RETURNING id, created_at
+    `, r.tenant, keyHash, key.Name, key.Role, key.UserID, key.TeamName).Scan(&key.ID, &key.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("failed to create api key: %w", err)
+    }
+    return nil
+}
+
+func (r *APIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*entity.APIKey, string, error) {
+    var key entity.APIKey
+    var tenantID string
+
+    err := r.db.QueryRowContext(ctx, `
+        SELECT id, tenant_id, name, role, COALESCE(user_id, ''), COALESCE(team_name, ''), created_at
+        FROM api_keys
+        WHERE key_hash = $1 AND revoked_at IS NULL
+    `, keyHash).Scan(&key.ID, &tenantID, &key.Name, &key.Role, &key.UserID, &key.TeamName, &key.CreatedAt)
+    if err == sql.ErrNoRows {
+        return nil, "", domain.NotFound("api key not found")
+    }
+    if err != nil {
+        return nil, "", fmt.Errorf("failed to get api key: %w", err)
+    }
+
+    return &key, tenantID, nil
+}
+
+// Revoke marks a key revoked. Revoking a revoked key keeps the original
+// revocation time.
+func (r *APIKeyRepository) Revoke(ctx context.Context, keyID int64) (*entity.APIKey, error) {
+    var key entity.APIKey
+
+    err := r.db.QueryRowContext(ctx, `
+        UPDATE api_keys
+        SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP)
+        WHERE tenant_id = $1 AND id = $2
+        RETURNING id, name, role, COALESCE(user_id, ''), COALESCE(team_name, ''), created_at, revoked_at
+    `, r.tenant, keyID).Scan(&key.ID, &key.Name, &key.Role, &key.UserID, &key.TeamName, &key.CreatedAt, &key.RevokedAt)
+    if err == sql.ErrNoRows {
+        return nil, domain.NotFound("api key %d not found", keyID)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to revoke api key: %w", err)
+    }
+
+    return &key, nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type CodeownersRepository struct {
+    db     dbtx
+    tenant string
+}
+
+func NewCodeownersRepository(s *Storage) repo.CodeownersRepository {
+    return &CodeownersRepository{db: s.conn()}
+}
+
+func (r *CodeownersRepository) ForTenant(tenantID string) repo.CodeownersRepository {
+    return &CodeownersRepository{db: r.db, tenant: tenantID}
+}
+
+func (r *CodeownersRepository) Get(ctx context.Context, repositoryID string) (string, error) {
+    var content string
+    err := r.db.QueryRowContext(ctx, "SELECT content FROM codeowners WHERE tenant_id = $1 AND repository_id = $2", r.tenant, repositoryID).Scan(&content)
+    if err == sql.ErrNoRows {
+        return "", nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to get codeowners: %w", err)
+    }
+    return content, nil
+}
+
+func (r *CodeownersRepository) Set(ctx context.Context, repositoryID, content string) error {
+    _, err := r.db.ExecContext(ctx, `
+        INSERT INTO codeowners (tenant_id, repository_id, content)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (tenant_id, repository_id)
+        DO UPDATE SET content = EXCLUDED.content, updated_at = CURRENT_TIMESTAMP
+    `, r.tenant, repositoryID, content)
+    if err != nil {
+        return fmt.Errorf("failed to save codeowners: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "time"
+    "github.com/shmul/avito-task/internal/domain"
+    "github.com/shmul/avito-task/internal/domain/entity"
+    "github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type IdempotencyRepository struct {
+    db     dbtx
+    tenant string
+    pruner *pruner
+}
+
+func NewIdempotencyRepository(s *Storage) repo.IdempotencyRepository {
+    return &IdempotencyRepository{db: s.conn(), pruner: newPruner()}
+}
+
+func (r *IdempotencyRepository) ForTenant(tenantID string) repo.IdempotencyRepository {
+    return &IdempotencyRepository{db: r.db, tenant: tenantID, pruner: r.pruner}
+}
+
+func (r *IdempotencyRepository) Begin(ctx context.Context, key, requestHash string, ttl time.Duration) (*entity.IdempotentRequest, error) {
+    r.prune(ctx)
+
+    ttlInterval := fmt.Sprintf("%d milliseconds", ttl.Milliseconds())
+
+    // the key may be released between the failed claim and the read, so
+    // try to claim it once more
+    for attempt := 0; attempt < 2; attempt++ {
+        var claimed bool
+        err := r.db.QueryRowContext(ctx, `
+            INSERT INTO idempotency_keys (tenant_id, key, request_hash, expires_at)
+            VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4::interval)
+            ON CONFLICT (tenant_id, key) DO UPDATE
+            SET request_hash = EXCLUDED.request_hash,
+                status_code = NULL,
+                headers = NULL,
+                body = NULL,
+                created_at = CURRENT_TIMESTAMP,
+                expires_at = EXCLUDED.expires_at
+            WHERE idempotency_keys.expires_at < CURRENT_TIMESTAMP
+            RETURNING true
+        `, r.tenant, key, requestHash, ttlInterval).Scan(&claimed)
+        if err == nil {
+            return nil, nil
+        }
+        if err != sql.ErrNoRows {
+            return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+        }
+
+        request := entity.IdempotentRequest{Key: key}
+        var statusCode sql.NullInt64
+        var headers []byte
+        err = r.db.QueryRowContext(ctx, `
+            SELECT request_hash, status_code, headers, body
+            FROM idempotency_keys
+            WHERE tenant_id = $1 AND key = $2
+        `, r.tenant, key).Scan(&request.RequestHash, &statusCode, &headers, &request.Body)
+        if err == sql.ErrNoRows {
+            continue
+        }
+        if err != nil {
+            return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+        }
+
+        request.StatusCode = int(statusCode.Int64)
+        if headers != nil {
+            if err := json.Unmarshal(headers, &request.Header); err != nil {
+                return nil, fmt.Errorf("failed to decode stored headers: %w", err)
+            }
+        }
+        return &request, nil
+    }
+
+    return nil, domain.Conflict(domain.CodeRequestInProgress, "request with idempotency key %s is being processed", key)
+}
+
+func (r *IdempotencyRepository) Complete(ctx context.Context, key string, statusCode int, header map[string][]string, body []byte) error {
+    headers, err := json.Marshal(header)
+    if err != nil {
+        return fmt.Errorf("failed to encode headers: %w", err)
+    }
+
+    _, err = r.db.ExecContext(ctx, `
+        UPDATE idempotency_keys
+        SET status_code = $3, headers = $4::jsonb, body = $5
+        WHERE tenant_id = $1 AND key = $2
+    `, r.tenant, key, statusCode, string(headers), body)
+    if err != nil {
+        return fmt.Errorf("failed to store idempotent response: %w", err)
+    }
+    return nil
+}
+
+func (r *IdempotencyRepository) Release(ctx context.Context, key string) error {
+    _, err := r.db.ExecContext(ctx, `
+        DELETE FROM idempotency_keys
+        WHERE tenant_id = $1 AND key = $2 AND status_code IS NULL
+    `, r.tenant, key)
+    if err != nil {
+        return fmt.Errorf("failed to release idempotency key: %w", err)
+    }
+    return nil
+}
+
+// prune drops expired keys of all tenants.
+func (r *IdempotencyRepository) prune(ctx context.Context) {
+    if !r.pruner.due() {
+        return
+    }
+
+    // best effort, a failed prune is retried next time
+    r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < CURRENT_TIMESTAMP`)
+}
@@ -6,11 +6,16 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+const uniqueViolation = "23505"
+
 type Storage struct{
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
 func NewConnection(cfg *config.Config) (*Storage, error){
@@ -34,7 +39,7 @@ func NewConnection(cfg *config.Config) (*Storage, error){
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &Storage{db: db}, nil
+	return &Storage{db: db, queryTimeout: cfg.Database.QueryTimeout}, nil
 }
 
 func (s *Storage) Close() error {
@@ -45,6 +50,17 @@ func (s *Storage) DB() *sql.DB {
 	return s.db
 }
 
+// conn is what repositories run their statements on, each bounded by the
+// configured query timeout.
+func (s *Storage) conn() *conn {
+	return &conn{q: s.db, timeout: s.queryTimeout}
+}
+
 func (s *Storage) HealthCheck(ctx context.Context) error {
 	return s.db.PingContext(ctx)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
 }
\ No newline at end of file
@@ -1,34 +1,51 @@
 package postgres
 
 import (
+    "context"
     "database/sql"
+    "encoding/json"
     "fmt"
+    "slices"
     "time"
+    "github.com/shmul/avito-task/internal/domain"
     "github.com/shmul/avito-task/internal/domain/entity"
     "github.com/shmul/avito-task/internal/domain/repo"
 )
 
 type PRRepository struct {
-    db *sql.DB
+    db     dbtx
+    tenant string
 }
 
-func NewPRRepository(db *sql.DB) repo.PRRepository {
-    return &PRRepository{db: db}
+func NewPRRepository(s *Storage) repo.PRRepository {
+    return &PRRepository{db: s.conn()}
 }
 
-func (r *PRRepository) Create(pr *entity.PullRequest) error {
-    tx, err := r.db.Begin()
+func (r *PRRepository) ForTenant(tenantID string) repo.PRRepository {
+    return &PRRepository{db: r.db, tenant: tenantID}
+}
+
+func (r *PRRepository) Create(ctx context.Context, pr *entity.PullRequest, change entity.Change) error {
+    tx, err := begin(ctx, r.db)
     if err != nil {
         return err
     }
     defer tx.Rollback()
 
+    changedFiles, err := json.Marshal(pr.ChangedFiles)
+    if err != nil {
+        return fmt.Errorf("failed to encode changed files: %w", err)
+    }
+
     var createdAt time.Time
-    err = tx.QueryRow(`
-        INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status)
-        VALUES ($1, $2, $3, $4)
-        RETURNING created_at
-    `, pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status).Scan(&createdAt)
+    err = tx.QueryRowContext(ctx, `
+        INSERT INTO pull_requests (tenant_id, pull_request_id, pull_request_name, author_id, status, repository_id, changed_files)
+        VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb)
+        RETURNING created_at, version
+    `, r.tenant, pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, pr.RepositoryID, string(changedFiles)).Scan(&createdAt, &pr.Version)
+    if isUniqueViolation(err) {
+        return domain.Conflict(domain.CodePRExists, "PR %s already exists", pr.PullRequestID)
+    }
     if err != nil {
         return fmt.Errorf("failed to create PR: %w", err)
     }
@@ -36,122 +53,167 @@ func (r *PRRepository) Create(pr *entity.PullRequest) error {
     pr.CreatedAt = &createdAt
 
     for _, reviewerID := range pr.AssignedReviewers {
-        _, err = tx.Exec(`
-            INSERT INTO pr_reviewers (pull_request_id, reviewer_id)
-            VALUES ($1, $2)
-            ON CONFLICT (pull_request_id, reviewer_id) DO NOTHING
-        `, pr.PullRequestID, reviewerID)
+        _, err = tx.ExecContext(ctx, `
+            INSERT INTO pr_reviewers (tenant_id, pull_request_id, reviewer_id, is_fallback, source)
+            VALUES ($1, $2, $3, $4, $5)
+            ON CONFLICT (tenant_id, pull_request_id, reviewer_id) DO NOTHING
+        `, r.tenant, pr.PullRequestID, reviewerID, slices.Contains(pr.FallbackReviewers, reviewerID), pr.ReviewerSources[reviewerID])
         if err != nil {
             return fmt.Errorf("failed to assign reviewer %s: %w", reviewerID, err)
         }
     }
 
+    events := diffEvents("", pr.Status, nil, pr.AssignedReviewers)
+    if err := appendEvents(ctx, tx, r.tenant, pr.PullRequestID, change, events); err != nil {
+        return err
+    }
+    if err := appendOutbox(ctx, tx, r.tenant, webhookPayloads(pr, events)); err != nil {
+        return err
+    }
+
     return tx.Commit()
 }
 
-func (r *PRRepository) GetByID(prID string) (*entity.PullRequest, error) {
+func (r *PRRepository) GetByID(ctx context.Context, prID string) (*entity.PullRequest, error) {
     var pr entity.PullRequest
-    var mergedAt sql.NullTime
+    var mergedAt, closedAt sql.NullTime
+    var changedFiles []byte
     
-    err := r.db.QueryRow(`
-        SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at
+    err := r.db.QueryRowContext(ctx, `
+        SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at, closed_at,
+               COALESCE(merged_by, ''), force_merged, repository_id, changed_files, version
         FROM pull_requests 
-        WHERE pull_request_id = $1
-    `, prID).Scan(
+        WHERE tenant_id = $1 AND pull_request_id = $2
+    `, r.tenant, prID).Scan(
         &pr.PullRequestID,
         &pr.PullRequestName,
         &pr.AuthorID,
         &pr.Status,
         &pr.CreatedAt,
         &mergedAt,
+        &closedAt,
+        &pr.MergedBy,
+        &pr.ForceMerged,
+        &pr.RepositoryID,
+        &changedFiles,
+        &pr.Version,
     )
     
     if err == sql.ErrNoRows {
-        return nil, fmt.Errorf("PR not found: %s", prID)
+        return nil, domain.NotFound("PR %s not found", prID)
     }
     if err != nil {
         return nil, fmt.Errorf("failed to get PR: %w", err)
     }
+    if err := json.Unmarshal(changedFiles, &pr.ChangedFiles); err != nil {
+        return nil, fmt.Errorf("failed to decode changed files: %w", err)
+    }
 
     if mergedAt.Valid {
         pr.MergedAt = &mergedAt.Time
     }
-
-    rows, err := r.db.Query(`
-        SELECT reviewer_id 
-        FROM pr_reviewers 
-        WHERE pull_request_id = $1
-        ORDER BY reviewer_id
-    `, prID)
-    if err != nil {
-        return nil, fmt.Errorf("failed to get reviewers: %w", err)
-    }
-    defer rows.Close()
-
-    var reviewers []string
-    for rows.Next() {
-        var reviewerID string
-        if err := rows.Scan(&reviewerID); err != nil {
-            return nil, fmt.Errorf("failed to scan reviewer: %w", err)
-        }
-        reviewers = append(reviewers, reviewerID)
+    if closedAt.Valid {
+        pr.ClosedAt = &closedAt.Time
     }
 
-    if err := rows.Err(); err != nil {
-        return nil, fmt.Errorf("error iterating reviewers: %w", err)
+    if err := r.loadReviewers(ctx, &pr); err != nil {
+        return nil, err
     }
-
-    pr.AssignedReviewers = reviewers
     return &pr, nil
 }
 
-func (r *PRRepository) Update(pr *entity.PullRequest) error {
-    tx, err := r.db.Begin()
+// Update saves pr if the stored PR is still at pr.Version and advances the
+// version; a PR changed in between fails with a CONFLICT error.
+func (r *PRRepository) Update(ctx context.Context, pr *entity.PullRequest, change entity.Change) error {
+    tx, err := begin(ctx, r.db)
     if err != nil {
         return err
     }
     defer tx.Rollback()
 
-    var mergedAt sql.NullTime
+    // the row lock keeps the state diffed into events consistent with the write
+    var oldStatus entity.PRStatus
+    var version int64
+    err = tx.QueryRowContext(ctx, "SELECT status, version FROM pull_requests WHERE tenant_id = $1 AND pull_request_id = $2 FOR UPDATE", r.tenant, pr.PullRequestID).Scan(&oldStatus, &version)
+    if err == sql.ErrNoRows {
+        return domain.NotFound("PR %s not found", pr.PullRequestID)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to lock PR: %w", err)
+    }
+    // pr was read at pr.Version; anything written since would be overwritten
+    if version != pr.Version {
+        return domain.Conflict(domain.CodeConflict, "PR %s was modified concurrently", pr.PullRequestID)
+    }
+
+    oldReviewers, err := reviewerIDs(ctx, tx, r.tenant, pr.PullRequestID)
+    if err != nil {
+        return err
+    }
+
+    var mergedAt, closedAt sql.NullTime
     if pr.MergedAt != nil {
         mergedAt = sql.NullTime{Time: *pr.MergedAt, Valid: true}
     }
+    if pr.ClosedAt != nil {
+        closedAt = sql.NullTime{Time: *pr.ClosedAt, Valid: true}
+    }
 
-    _, err = tx.Exec(`
+    err = tx.QueryRowContext(ctx, `
         UPDATE pull_requests 
-        SET pull_request_name = $1, status = $2, merged_at = $3
-        WHERE pull_request_id = $4
-    `, pr.PullRequestName, pr.Status, mergedAt, pr.PullRequestID)
+        SET pull_request_name = $1, status = $2, merged_at = $3, closed_at = $4,
+            merged_by = NULLIF($5, ''), force_merged = $6, version = version + 1
+        WHERE tenant_id = $7 AND pull_request_id = $8
+        RETURNING version
+    `, pr.PullRequestName, pr.Status, mergedAt, closedAt, pr.MergedBy, pr.ForceMerged, r.tenant, pr.PullRequestID).Scan(&version)
     if err != nil {
         return fmt.Errorf("failed to update PR: %w", err)
     }
 
-    _, err = tx.Exec("DELETE FROM pr_reviewers WHERE pull_request_id = $1", pr.PullRequestID)
+    // reviewers that stay keep their assigned_at and review state
+    _, err = tx.ExecContext(ctx, `
+        DELETE FROM pr_reviewers
+        WHERE tenant_id = $1 AND pull_request_id = $2 AND NOT (reviewer_id = ANY(COALESCE($3, '{}'::text[])))
+    `, r.tenant, pr.PullRequestID, pr.AssignedReviewers)
     if err != nil {
         return fmt.Errorf("failed to clear reviewers: %w", err)
     }
 
     for _, reviewerID := range pr.AssignedReviewers {
-        _, err = tx.Exec(`
-            INSERT INTO pr_reviewers (pull_request_id, reviewer_id)
-            VALUES ($1, $2)
-        `, pr.PullRequestID, reviewerID)
+        _, err = tx.ExecContext(ctx, `
+            INSERT INTO pr_reviewers (tenant_id, pull_request_id, reviewer_id, is_fallback, source)
+            VALUES ($1, $2, $3, $4, $5)
+            ON CONFLICT (tenant_id, pull_request_id, reviewer_id) DO UPDATE SET is_fallback = EXCLUDED.is_fallback
+        `, r.tenant, pr.PullRequestID, reviewerID, slices.Contains(pr.FallbackReviewers, reviewerID), pr.ReviewerSources[reviewerID])
         if err != nil {
             return fmt.Errorf("failed to assign reviewer %s: %w", reviewerID, err)
         }
     }
 
-    return tx.Commit()
+    events := diffEvents(oldStatus, pr.Status, oldReviewers, pr.AssignedReviewers)
+    if err := appendEvents(ctx, tx, r.tenant, pr.PullRequestID, change, events); err != nil {
+        return err
+    }
+    if err := appendOutbox(ctx, tx, r.tenant, webhookPayloads(pr, events)); err != nil {
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    pr.Version = version
+    return nil
 }
 
-func (r *PRRepository) GetByReviewer(userID string) ([]*entity.PullRequest, error) {
-    rows, err := r.db.Query(`
-        SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at
+func (r *PRRepository) GetByReviewer(ctx context.Context, userID string) ([]*entity.PullRequest, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at, pr.closed_at,
+               COALESCE(pr.merged_by, ''), pr.force_merged, pr.repository_id, pr.version
         FROM pull_requests pr
-        JOIN pr_reviewers prr ON pr.pull_request_id = prr.pull_request_id
-        WHERE prr.reviewer_id = $1
+        JOIN pr_reviewers prr ON prr.tenant_id = pr.tenant_id AND prr.pull_request_id = pr.pull_request_id
+        WHERE pr.tenant_id = $1 AND prr.reviewer_id = $2
         ORDER BY pr.created_at DESC
-    `, userID)
+    `, r.tenant, userID)
     if err != nil {
         return nil, fmt.Errorf("failed to get PRs by reviewer: %w", err)
     }
@@ -160,7 +222,7 @@ func (r *PRRepository) GetByReviewer(userID string) ([]*entity.PullRequest, erro
     var prs []*entity.PullRequest
     for rows.Next() {
         var pr entity.PullRequest
-        var mergedAt sql.NullTime
+        var mergedAt, closedAt sql.NullTime
         
         err := rows.Scan(
             &pr.PullRequestID,
@@ -169,6 +231,11 @@ func (r *PRRepository) GetByReviewer(userID string) ([]*entity.PullRequest, erro
             &pr.Status,
             &pr.CreatedAt,
             &mergedAt,
+            &closedAt,
+            &pr.MergedBy,
+            &pr.ForceMerged,
+            &pr.RepositoryID,
+            &pr.Version,
         )
         if err != nil {
             return nil, fmt.Errorf("failed to scan PR: %w", err)
@@ -177,27 +244,68 @@ func (r *PRRepository) GetByReviewer(userID string) ([]*entity.PullRequest, erro
         if mergedAt.Valid {
             pr.MergedAt = &mergedAt.Time
         }
+        if closedAt.Valid {
+            pr.ClosedAt = &closedAt.Time
+        }
 
-        reviewerRows, err := r.db.Query(`
-            SELECT reviewer_id FROM pr_reviewers WHERE pull_request_id = $1
-        `, pr.PullRequestID)
-        if err != nil {
-            return nil, fmt.Errorf("failed to get reviewers for PR %s: %w", pr.PullRequestID, err)
+        prs = append(prs, &pr)
+    }
+
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("error iterating PRs: %w", err)
+    }
+    rows.Close()
+
+    // a transaction runs one query at a time, so reviewers are loaded only
+    // after the PR rows are drained
+    for _, pr := range prs {
+        if err := r.loadReviewers(ctx, pr); err != nil {
+            return nil, err
         }
+    }
 
-        var reviewers []string
-        for reviewerRows.Next() {
-            var reviewerID string
-            if err := reviewerRows.Scan(&reviewerID); err != nil {
-                reviewerRows.Close()
-                return nil, fmt.Errorf("failed to scan reviewer: %w", err)
-            }
-            reviewers = append(reviewers, reviewerID)
+    return prs, nil
+}
+
+// GetOpenByReviewers returns the OPEN PRs any of reviewerIDs is assigned to,
+// with their reviewers but without review details, in a single query. Within
+// a transaction the PRs stay locked until it ends.
+func (r *PRRepository) GetOpenByReviewers(ctx context.Context, reviewerIDs []string) ([]*entity.PullRequest, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.repository_id, pr.created_at, pr.version, prr.reviewer_id, prr.is_fallback
+        FROM pull_requests pr
+        JOIN pr_reviewers prr ON prr.tenant_id = pr.tenant_id AND prr.pull_request_id = pr.pull_request_id
+        WHERE pr.tenant_id = $1 AND pr.status = 'OPEN' AND EXISTS (
+            SELECT 1 FROM pr_reviewers held
+            WHERE held.tenant_id = pr.tenant_id AND held.pull_request_id = pr.pull_request_id AND held.reviewer_id = ANY($2)
+        )
+        ORDER BY pr.pull_request_id, prr.reviewer_id
+        FOR UPDATE OF pr
+    `, r.tenant, reviewerIDs)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get open PRs by reviewers: %w", err)
+    }
+    defer rows.Close()
+
+    var prs []*entity.PullRequest
+    var pr *entity.PullRequest
+    for rows.Next() {
+        var row entity.PullRequest
+        var reviewerID string
+        var isFallback bool
+        if err := rows.Scan(&row.PullRequestID, &row.PullRequestName, &row.AuthorID, &row.RepositoryID, &row.CreatedAt, &row.Version, &reviewerID, &isFallback); err != nil {
+            return nil, fmt.Errorf("failed to scan PR reviewer: %w", err)
         }
-        reviewerRows.Close()
 
-        pr.AssignedReviewers = reviewers
-        prs = append(prs, &pr)
+        if pr == nil || pr.PullRequestID != row.PullRequestID {
+            pr = &row
+            pr.Status = entity.StatusOpen
+            prs = append(prs, pr)
+        }
+        pr.AssignedReviewers = append(pr.AssignedReviewers, reviewerID)
+        if isFallback {
+            pr.FallbackReviewers = append(pr.FallbackReviewers, reviewerID)
+        }
     }
 
     if err := rows.Err(); err != nil {
@@ -207,10 +315,633 @@ func (r *PRRepository) GetByReviewer(userID string) ([]*entity.PullRequest, erro
     return prs, nil
 }
 
-func (r *PRRepository) Exists(prID string) (bool, error) {
-    query := `SELECT EXISTS(SELECT 1 FROM pull_requests WHERE pull_request_id = $1)`
+// ReplaceReviewers applies changes with one delete and one insert, however
+// many PRs they touch. Every PR has to be still OPEN at the version its
+// changes were decided on, or none are applied and it fails with a CONFLICT
+// error.
+func (r *PRRepository) ReplaceReviewers(ctx context.Context, changes []repo.ReviewerChange, change entity.Change) error {
+    if len(changes) == 0 {
+        return nil
+    }
+
+    prIDs := make([]string, len(changes))
+    oldIDs := make([]string, len(changes))
+    newIDs := make([]string, len(changes))
+    fallbacks := make([]bool, len(changes))
+    versions := make(map[string]int64)
+    for i, change := range changes {
+        prIDs[i] = change.PullRequestID
+        oldIDs[i] = change.OldReviewerID
+        newIDs[i] = change.NewReviewerID
+        fallbacks[i] = change.Fallback
+        versions[change.PullRequestID] = change.Version
+    }
+
+    lockedIDs := make([]string, 0, len(versions))
+    lockedVersions := make([]int64, 0, len(versions))
+    for prID, version := range versions {
+        lockedIDs = append(lockedIDs, prID)
+        lockedVersions = append(lockedVersions, version)
+    }
+
+    tx, err := begin(ctx, r.db)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // advancing the versions first locks the PRs against every other writer,
+    // and a PR merged, closed or changed since it was read is left out
+    result, err := tx.ExecContext(ctx, `
+        UPDATE pull_requests pr SET version = pr.version + 1
+        FROM unnest($2::text[], $3::bigint[]) AS c(pull_request_id, version)
+        WHERE pr.tenant_id = $1 AND pr.pull_request_id = c.pull_request_id
+          AND pr.version = c.version AND pr.status = 'OPEN'
+    `, r.tenant, lockedIDs, lockedVersions)
+    if err != nil {
+        return fmt.Errorf("failed to bump PR versions: %w", err)
+    }
+    updated, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("failed to bump PR versions: %w", err)
+    }
+    if updated != int64(len(lockedIDs)) {
+        return domain.Conflict(domain.CodeConflict, "%d of %d PRs were modified concurrently", int64(len(lockedIDs))-updated, len(lockedIDs))
+    }
+
+    _, err = tx.ExecContext(ctx, `
+        DELETE FROM pr_reviewers prr
+        USING unnest($2::text[], $3::text[]) AS c(pull_request_id, reviewer_id)
+        WHERE prr.tenant_id = $1 AND prr.pull_request_id = c.pull_request_id AND prr.reviewer_id = c.reviewer_id
+    `, r.tenant, prIDs, oldIDs)
+    if err != nil {
+        return fmt.Errorf("failed to remove reviewers: %w", err)
+    }
+
+    _, err = tx.ExecContext(ctx, `
+        INSERT INTO pr_reviewers (tenant_id, pull_request_id, reviewer_id, is_fallback)
+        SELECT $1, c.pull_request_id, c.reviewer_id, c.is_fallback
+        FROM unnest($2::text[], $3::text[], $4::bool[]) AS c(pull_request_id, reviewer_id, is_fallback)
+        WHERE c.reviewer_id <> ''
+        ON CONFLICT (tenant_id, pull_request_id, reviewer_id) DO NOTHING
+    `, r.tenant, prIDs, newIDs, fallbacks)
+    if err != nil {
+        return fmt.Errorf("failed to assign reviewers: %w", err)
+    }
+
+    rows, err := tx.QueryContext(ctx, `
+        INSERT INTO assignment_events (tenant_id, pull_request_id, event_type, actor, reason, old_reviewer_id, new_reviewer_id)
+        SELECT $1, c.pull_request_id,
+               CASE WHEN c.new_reviewer_id = '' THEN 'UNASSIGNED' ELSE 'REASSIGNED' END,
+               NULLIF($5, ''), $6, c.old_reviewer_id, NULLIF(c.new_reviewer_id, '')
+        FROM unnest($2::text[], $3::text[], $4::text[]) WITH ORDINALITY AS c(pull_request_id, old_reviewer_id, new_reviewer_id, n)
+        ORDER BY c.n
+        RETURNING id, pull_request_id, event_type, old_reviewer_id, COALESCE(new_reviewer_id, ''), created_at
+    `, r.tenant, prIDs, oldIDs, newIDs, change.Actor, change.Reason)
+    if err != nil {
+        return fmt.Errorf("failed to record assignment events: %w", err)
+    }
+
+    var payloads []entity.WebhookPayload
+    byPR := make(map[string]int)
+    for rows.Next() {
+        e := entity.AssignmentEvent{Actor: change.Actor, Reason: change.Reason}
+        if err := rows.Scan(&e.ID, &e.PullRequestID, &e.Type, &e.OldReviewerID, &e.NewReviewerID, &e.CreatedAt); err != nil {
+            rows.Close()
+            return fmt.Errorf("failed to scan assignment event: %w", err)
+        }
+
+        i, ok := byPR[e.PullRequestID]
+        if !ok {
+            i = len(payloads)
+            byPR[e.PullRequestID] = i
+            payloads = append(payloads, entity.WebhookPayload{
+                Event:         entity.WebhookReviewersChanged,
+                OccurredAt:    e.CreatedAt,
+                PullRequestID: e.PullRequestID,
+            })
+        }
+        payloads[i].Changes = append(payloads[i].Changes, e)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("error iterating assignment events: %w", err)
+    }
+
+    if err := appendOutbox(ctx, tx, r.tenant, payloads); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+func (r *PRRepository) loadReviewers(ctx context.Context, pr *entity.PullRequest) error {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT reviewer_id, is_fallback, review_state, assigned_at, reviewed_at, source
+        FROM pr_reviewers 
+        WHERE tenant_id = $1 AND pull_request_id = $2
+        ORDER BY reviewer_id
+    `, r.tenant, pr.PullRequestID)
+    if err != nil {
+        return fmt.Errorf("failed to get reviewers for PR %s: %w", pr.PullRequestID, err)
+    }
+    defer rows.Close()
+
+    var reviewers, fallbacks []string
+    var reviews []entity.Review
+    sources := make(map[string]string)
+    for rows.Next() {
+        var review entity.Review
+        var isFallback bool
+        var reviewedAt sql.NullTime
+        var source string
+        if err := rows.Scan(&review.ReviewerID, &isFallback, &review.State, &review.AssignedAt, &reviewedAt, &source); err != nil {
+            return fmt.Errorf("failed to scan reviewer: %w", err)
+        }
+        if source != "" {
+            sources[review.ReviewerID] = source
+        }
+        if reviewedAt.Valid {
+            review.ReviewedAt = &reviewedAt.Time
+        }
+        reviewers = append(reviewers, review.ReviewerID)
+        reviews = append(reviews, review)
+        if isFallback {
+            fallbacks = append(fallbacks, review.ReviewerID)
+        }
+    }
+
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("error iterating reviewers: %w", err)
+    }
+
+    pr.AssignedReviewers = reviewers
+    pr.FallbackReviewers = fallbacks
+    pr.Reviews = reviews
+    pr.ReviewerSources = sources
+    return nil
+}
+
+// SubmitReview records reviewerID's review of the PR if the stored PR is
+// still at version and advances the version; a PR changed in between fails
+// with a CONFLICT error.
+func (r *PRRepository) SubmitReview(ctx context.Context, prID, reviewerID string, state entity.ReviewState, version int64) error {
+    tx, err := begin(ctx, r.db)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // the review was decided on the PR as read at version, a PR merged,
+    // closed or reassigned since must not take it
+    var current int64
+    err = tx.QueryRowContext(ctx, "SELECT version FROM pull_requests WHERE tenant_id = $1 AND pull_request_id = $2 FOR UPDATE", r.tenant, prID).Scan(&current)
+    if err == sql.ErrNoRows {
+        return domain.NotFound("PR %s not found", prID)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to lock PR: %w", err)
+    }
+    if current != version {
+        return domain.Conflict(domain.CodeConflict, "PR %s was modified concurrently", prID)
+    }
+
+    result, err := tx.ExecContext(ctx, `
+        UPDATE pr_reviewers
+        SET review_state = $3,
+            reviewed_at = CURRENT_TIMESTAMP,
+            first_reviewed_at = COALESCE(first_reviewed_at, CURRENT_TIMESTAMP)
+        WHERE tenant_id = $4 AND pull_request_id = $1 AND reviewer_id = $2
+    `, prID, reviewerID, state, r.tenant)
+    if err != nil {
+        return fmt.Errorf("failed to submit review: %w", err)
+    }
+
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("failed to submit review: %w", err)
+    }
+    if affected == 0 {
+        return domain.Conflict(domain.CodeNotAssigned, "reviewer is not assigned to this PR")
+    }
+
+    // a review changes the PR's representation, so it bumps the version too
+    if _, err := tx.ExecContext(ctx, "UPDATE pull_requests SET version = version + 1 WHERE tenant_id = $1 AND pull_request_id = $2", r.tenant, prID); err != nil {
+        return fmt.Errorf("failed to submit review: %w", err)
+    }
+
+    return tx.Commit()
+}
+
+func (r *PRRepository) Exists(ctx context.Context, prID string) (bool, error) {
+    query := `SELECT EXISTS(SELECT 1 FROM pull_requests WHERE tenant_id = $1 AND pull_request_id = $2)`
     
     var exists bool
-    err := r.db.QueryRow(query, prID).Scan(&exists)
+    err := r.db.QueryRowContext(ctx, query, r.tenant, prID).Scan(&exists)
     return exists, err
+}
+
+func (r *PRRepository) GetOpenReviewCounts(ctx context.Context, teamName string) (map[string]int, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT u.user_id, COUNT(pr.pull_request_id)
+        FROM users u
+        LEFT JOIN pr_reviewers prr ON prr.tenant_id = u.tenant_id AND prr.reviewer_id = u.user_id
+        LEFT JOIN pull_requests pr ON pr.tenant_id = prr.tenant_id AND pr.pull_request_id = prr.pull_request_id AND pr.status = 'OPEN'
+        WHERE u.tenant_id = $1 AND u.team_name = $2
+        GROUP BY u.user_id
+    `, r.tenant, teamName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get open review counts: %w", err)
+    }
+    defer rows.Close()
+
+    counts := make(map[string]int)
+    for rows.Next() {
+        var userID string
+        var count int
+        if err := rows.Scan(&userID, &count); err != nil {
+            return nil, fmt.Errorf("failed to scan review count: %w", err)
+        }
+        counts[userID] = count
+    }
+
+    return counts, rows.Err()
+}
+
+func (r *PRRepository) QueueForAssignment(ctx context.Context, prID string) error {
+    _, err := r.db.ExecContext(ctx, `
+        INSERT INTO pr_assignment_queue (tenant_id, pull_request_id)
+        VALUES ($1, $2)
+        ON CONFLICT (tenant_id, pull_request_id) DO NOTHING
+    `, r.tenant, prID)
+    if err != nil {
+        return fmt.Errorf("failed to queue PR %s: %w", prID, err)
+    }
+    return nil
+}
+
+func (r *PRRepository) GetQueuedForAssignment(ctx context.Context) ([]string, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT pull_request_id
+        FROM pr_assignment_queue
+        WHERE tenant_id = $1
+        ORDER BY queued_at, pull_request_id
+    `, r.tenant)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get assignment queue: %w", err)
+    }
+    defer rows.Close()
+
+    var prIDs []string
+    for rows.Next() {
+        var prID string
+        if err := rows.Scan(&prID); err != nil {
+            return nil, fmt.Errorf("failed to scan queued PR: %w", err)
+        }
+        prIDs = append(prIDs, prID)
+    }
+
+    return prIDs, rows.Err()
+}
+
+func (r *PRRepository) RemoveFromAssignmentQueue(ctx context.Context, prID string) error {
+    _, err := r.db.ExecContext(ctx, "DELETE FROM pr_assignment_queue WHERE tenant_id = $1 AND pull_request_id = $2", r.tenant, prID)
+    if err != nil {
+        return fmt.Errorf("failed to dequeue PR %s: %w", prID, err)
+    }
+    return nil
+}
+
+// reviewerStatsQuery aggregates assignments per user of tenant $4. $1 and $2
+// bound the time window (assigned_at for assignments, event time for
+// reassignments) and $3 is an optional team filter.
+const reviewerStatsQuery = `
+    SELECT u.user_id, u.username, u.team_name,
+           COUNT(a.pull_request_id) + COALESCE(ra.count, 0) AS total_assignments,
+           COUNT(a.pull_request_id) FILTER (WHERE a.status = 'OPEN') AS open_assignments,
+           COUNT(a.pull_request_id) FILTER (WHERE a.status = 'MERGED') AS merged_reviewed,
+           COALESCE(ra.count, 0) AS reassigned_away
+    FROM users u
+    LEFT JOIN (
+        SELECT prr.reviewer_id, pr.pull_request_id, pr.status
+        FROM pr_reviewers prr
+        JOIN pull_requests pr ON pr.tenant_id = prr.tenant_id AND pr.pull_request_id = prr.pull_request_id
+        WHERE prr.tenant_id = $4
+          AND ($1::timestamptz IS NULL OR prr.assigned_at >= $1)
+          AND ($2::timestamptz IS NULL OR prr.assigned_at < $2)
+    ) a ON a.reviewer_id = u.user_id
+    LEFT JOIN (
+        SELECT old_reviewer_id, COUNT(*) AS count
+        FROM assignment_events
+        WHERE tenant_id = $4 AND event_type IN ('REASSIGNED', 'UNASSIGNED')
+          AND ($1::timestamptz IS NULL OR created_at >= $1)
+          AND ($2::timestamptz IS NULL OR created_at < $2)
+        GROUP BY old_reviewer_id
+    ) ra ON ra.old_reviewer_id = u.user_id
+    WHERE u.tenant_id = $4 AND ($3 = '' OR u.team_name = $3)
+    GROUP BY u.user_id, u.username, u.team_name, ra.count
+`
+
+func (r *PRRepository) GetReviewerStats(ctx context.Context, filter entity.StatsFilter) ([]*entity.ReviewerStats, error) {
+    rows, err := r.db.QueryContext(ctx, reviewerStatsQuery+`
+        ORDER BY u.team_name, u.user_id
+    `, filter.From, filter.To, filter.TeamName, r.tenant)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get reviewer stats: %w", err)
+    }
+    defer rows.Close()
+
+    var stats []*entity.ReviewerStats
+    for rows.Next() {
+        var s entity.ReviewerStats
+        err := rows.Scan(&s.UserID, &s.Username, &s.TeamName, &s.TotalAssignments, &s.OpenAssignments, &s.MergedReviewed, &s.ReassignedAway)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan reviewer stats: %w", err)
+        }
+        stats = append(stats, &s)
+    }
+
+    return stats, rows.Err()
+}
+
+func (r *PRRepository) GetTeamStats(ctx context.Context, filter entity.StatsFilter) ([]*entity.TeamStats, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT team_name, COUNT(*), SUM(total_assignments), SUM(open_assignments),
+               SUM(merged_reviewed), SUM(reassigned_away)
+        FROM (`+reviewerStatsQuery+`) s
+        GROUP BY team_name
+        ORDER BY team_name
+    `, filter.From, filter.To, filter.TeamName, r.tenant)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get team stats: %w", err)
+    }
+    defer rows.Close()
+
+    var stats []*entity.TeamStats
+    for rows.Next() {
+        var s entity.TeamStats
+        err := rows.Scan(&s.TeamName, &s.Members, &s.TotalAssignments, &s.OpenAssignments, &s.MergedReviewed, &s.ReassignedAway)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan team stats: %w", err)
+        }
+        stats = append(stats, &s)
+    }
+
+    return stats, rows.Err()
+}
+
+// mergeLatencyKeys maps a metric group to the column it groups by.
+var mergeLatencyKeys = map[entity.MetricGroup]string{
+    entity.GroupTeam:   "team_name",
+    entity.GroupAuthor: "author_id",
+}
+
+// GetTimeToMerge returns creation-to-merge percentiles of PRs merged within
+// the filter window, grouped by the author's team or by author.
+func (r *PRRepository) GetTimeToMerge(ctx context.Context, filter entity.StatsFilter, group entity.MetricGroup) ([]*entity.Latency, error) {
+    key, ok := mergeLatencyKeys[group]
+    if !ok {
+        return nil, fmt.Errorf("unknown metric group: %s", group)
+    }
+
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT `+key+`, COUNT(*),
+               percentile_cont(0.5) WITHIN GROUP (ORDER BY seconds),
+               percentile_cont(0.9) WITHIN GROUP (ORDER BY seconds),
+               percentile_cont(0.99) WITHIN GROUP (ORDER BY seconds)
+        FROM (
+            SELECT u.team_name, pr.author_id, EXTRACT(EPOCH FROM pr.merged_at - pr.created_at) AS seconds
+            FROM pull_requests pr
+            JOIN users u ON u.tenant_id = pr.tenant_id AND u.user_id = pr.author_id
+            WHERE pr.tenant_id = $4 AND pr.status = 'MERGED'
+              AND ($1::timestamptz IS NULL OR pr.merged_at >= $1)
+              AND ($2::timestamptz IS NULL OR pr.merged_at < $2)
+              AND ($3 = '' OR u.team_name = $3)
+        ) merged
+        GROUP BY `+key+`
+        ORDER BY `+key+`
+    `, filter.From, filter.To, filter.TeamName, r.tenant)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get time to merge: %w", err)
+    }
+    defer rows.Close()
+
+    return scanLatencies(rows)
+}
+
+// GetTimeToFirstReview returns assignment-to-first-decision percentiles of
+// reviews first submitted within the filter window, grouped by the
+// reviewer's team.
+func (r *PRRepository) GetTimeToFirstReview(ctx context.Context, filter entity.StatsFilter) ([]*entity.Latency, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT u.team_name, COUNT(*),
+               percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM prr.first_reviewed_at - prr.assigned_at)),
+               percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM prr.first_reviewed_at - prr.assigned_at)),
+               percentile_cont(0.99) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM prr.first_reviewed_at - prr.assigned_at))
+        FROM pr_reviewers prr
+        JOIN users u ON u.tenant_id = prr.tenant_id AND u.user_id = prr.reviewer_id
+        WHERE prr.tenant_id = $4 AND prr.first_reviewed_at IS NOT NULL
+          AND ($1::timestamptz IS NULL OR prr.first_reviewed_at >= $1)
+          AND ($2::timestamptz IS NULL OR prr.first_reviewed_at < $2)
+          AND ($3 = '' OR u.team_name = $3)
+        GROUP BY u.team_name
+        ORDER BY u.team_name
+    `, filter.From, filter.To, filter.TeamName, r.tenant)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get time to first review: %w", err)
+    }
+    defer rows.Close()
+
+    return scanLatencies(rows)
+}
+
+func scanLatencies(rows *timedRows) ([]*entity.Latency, error) {
+    latencies := []*entity.Latency{}
+    for rows.Next() {
+        var l entity.Latency
+        if err := rows.Scan(&l.Key, &l.Count, &l.P50Seconds, &l.P90Seconds, &l.P99Seconds); err != nil {
+            return nil, fmt.Errorf("failed to scan latency: %w", err)
+        }
+        latencies = append(latencies, &l)
+    }
+
+    return latencies, rows.Err()
+}
+
+func (r *PRRepository) GetHistory(ctx context.Context, prID string) ([]*entity.AssignmentEvent, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT id, pull_request_id, event_type, COALESCE(actor, ''), reason,
+               COALESCE(old_reviewer_id, ''), COALESCE(new_reviewer_id, ''),
+               COALESCE(old_status, ''), COALESCE(new_status, ''), created_at
+        FROM assignment_events
+        WHERE tenant_id = $1 AND pull_request_id = $2
+        ORDER BY id
+    `, r.tenant, prID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get PR history: %w", err)
+    }
+    defer rows.Close()
+
+    events := []*entity.AssignmentEvent{}
+    for rows.Next() {
+        var e entity.AssignmentEvent
+        err := rows.Scan(&e.ID, &e.PullRequestID, &e.Type, &e.Actor, &e.Reason,
+            &e.OldReviewerID, &e.NewReviewerID, &e.OldStatus, &e.NewStatus, &e.CreatedAt)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan assignment event: %w", err)
+        }
+        events = append(events, &e)
+    }
+
+    return events, rows.Err()
+}
+
+func reviewerIDs(ctx context.Context, db dbtx, tenant, prID string) ([]string, error) {
+    rows, err := db.QueryContext(ctx, "SELECT reviewer_id FROM pr_reviewers WHERE tenant_id = $1 AND pull_request_id = $2 ORDER BY reviewer_id", tenant, prID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get reviewers for PR %s: %w", prID, err)
+    }
+    defer rows.Close()
+
+    var ids []string
+    for rows.Next() {
+        var id string
+        if err := rows.Scan(&id); err != nil {
+            return nil, fmt.Errorf("failed to scan reviewer: %w", err)
+        }
+        ids = append(ids, id)
+    }
+
+    return ids, rows.Err()
+}
+
+// diffEvents describes the move from one PR state to another. Reviewers
+// that left and joined in the same change are paired up as reassignments.
+func diffEvents(oldStatus, newStatus entity.PRStatus, oldReviewers, newReviewers []string) []entity.AssignmentEvent {
+    var events []entity.AssignmentEvent
+    if oldStatus != newStatus {
+        eventType := entity.EventStatusChanged
+        if newStatus == entity.StatusMerged {
+            eventType = entity.EventMerged
+        }
+        events = append(events, entity.AssignmentEvent{Type: eventType, OldStatus: oldStatus, NewStatus: newStatus})
+    }
+
+    var removed, added []string
+    for _, reviewerID := range oldReviewers {
+        if !slices.Contains(newReviewers, reviewerID) {
+            removed = append(removed, reviewerID)
+        }
+    }
+    for _, reviewerID := range newReviewers {
+        if !slices.Contains(oldReviewers, reviewerID) {
+            added = append(added, reviewerID)
+        }
+    }
+
+    for len(removed) > 0 && len(added) > 0 {
+        events = append(events, entity.AssignmentEvent{Type: entity.EventReassigned, OldReviewerID: removed[0], NewReviewerID: added[0]})
+        removed, added = removed[1:], added[1:]
+    }
+    for _, reviewerID := range removed {
+        events = append(events, entity.AssignmentEvent{Type: entity.EventUnassigned, OldReviewerID: reviewerID})
+    }
+    for _, reviewerID := range added {
+        events = append(events, entity.AssignmentEvent{Type: entity.EventAssigned, NewReviewerID: reviewerID})
+    }
+
+    return events
+}
+
+// appendEvents records events and fills in what the database assigns.
+func appendEvents(ctx context.Context, tx dbtx, tenant, prID string, change entity.Change, events []entity.AssignmentEvent) error {
+    for i := range events {
+        e := &events[i]
+        e.PullRequestID, e.Actor, e.Reason = prID, change.Actor, change.Reason
+        err := tx.QueryRowContext(ctx, `
+            INSERT INTO assignment_events
+                (tenant_id, pull_request_id, event_type, actor, reason, old_reviewer_id, new_reviewer_id, old_status, new_status)
+            VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''))
+            RETURNING id, created_at
+        `, tenant, prID, e.Type, e.Actor, e.Reason, e.OldReviewerID, e.NewReviewerID, e.OldStatus, e.NewStatus).Scan(&e.ID, &e.CreatedAt)
+        if err != nil {
+            return fmt.Errorf("failed to record assignment event: %w", err)
+        }
+    }
+    return nil
+}
+
+// webhookPayloads groups the events of one change into webhook payloads: one
+// for the status move and one for the reviewer changes. Creation reports the
+// initial reviewers together with the PR.
+func webhookPayloads(pr *entity.PullRequest, events []entity.AssignmentEvent) []entity.WebhookPayload {
+    var payloads []entity.WebhookPayload
+    var reviewers []entity.AssignmentEvent
+    for _, e := range events {
+        switch {
+        case e.Type == entity.EventStatusChanged && e.OldStatus == "":
+            return []entity.WebhookPayload{{
+                Event:         entity.WebhookPRCreated,
+                OccurredAt:    e.CreatedAt,
+                PullRequestID: pr.PullRequestID,
+                PullRequest:   pr,
+                Changes:       events,
+            }}
+        case e.Type == entity.EventStatusChanged || e.Type == entity.EventMerged:
+            event := entity.WebhookPRStatusChanged
+            if e.Type == entity.EventMerged {
+                event = entity.WebhookPRMerged
+            }
+            payloads = append(payloads, entity.WebhookPayload{
+                Event:         event,
+                OccurredAt:    e.CreatedAt,
+                PullRequestID: pr.PullRequestID,
+                PullRequest:   pr,
+                Changes:       []entity.AssignmentEvent{e},
+            })
+        default:
+            reviewers = append(reviewers, e)
+        }
+    }
+
+    if len(reviewers) > 0 {
+        payloads = append(payloads, entity.WebhookPayload{
+            Event:         entity.WebhookReviewersChanged,
+            OccurredAt:    reviewers[0].CreatedAt,
+            PullRequestID: pr.PullRequestID,
+            PullRequest:   pr,
+            Changes:       reviewers,
+        })
+    }
+
+    return payloads
+}
+
+// appendOutbox queues payloads for the webhook dispatcher in one insert.
+func appendOutbox(ctx context.Context, tx dbtx, tenant string, payloads []entity.WebhookPayload) error {
+    if len(payloads) == 0 {
+        return nil
+    }
+
+    eventTypes := make([]string, len(payloads))
+    bodies := make([]string, len(payloads))
+    for i, payload := range payloads {
+        body, err := json.Marshal(payload)
+        if err != nil {
+            return fmt.Errorf("failed to encode webhook payload: %w", err)
+        }
+        eventTypes[i] = payload.Event
+        bodies[i] = string(body)
+    }
+
+    _, err := tx.ExecContext(ctx, `
+        INSERT INTO outbox (tenant_id, event_type, payload)
+        SELECT $1, o.event_type, o.payload::jsonb
+        FROM unnest($2::text[], $3::text[]) WITH ORDINALITY AS o(event_type, payload, n)
+        ORDER BY o.n
+    `, tenant, eventTypes, bodies)
+    if err != nil {
+        return fmt.Errorf("failed to write outbox: %w", err)
+    }
+    return nil
 }
\ No newline at end of file
@@ -0,0 +1,106 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+	"github.com/shmul/avito-task/internal/domain/service"
+)
+
+// TestReplaceReviewersRejectsStalePRs checks that the bulk reassignment of
+// deactivated reviewers does not overwrite PRs changed since it read them.
+func TestReplaceReviewersRejectsStalePRs(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+	tenant := fmt.Sprintf("replace-%d", time.Now().UnixNano())
+	prs := s.prs.ForTenant(tenant)
+	prRepo := s.prRepo.ForTenant(tenant)
+
+	team := &entity.Team{
+		TeamName: "backend",
+		Members: []entity.User{
+			{UserID: "u1", Username: "Alice", IsActive: true},
+			{UserID: "u2", Username: "Bob", IsActive: true},
+			{UserID: "u3", Username: "Carol", IsActive: true},
+			{UserID: "u4", Username: "Dave", IsActive: true},
+		},
+	}
+	if err := s.teams.ForTenant(tenant).CreateTeam(ctx, team); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(pr *entity.PullRequest) error
+	}{
+		{name: "merged", change: func(pr *entity.PullRequest) error {
+			_, err := prs.MergePR(ctx, pr.PullRequestID, false, &entity.Principal{UserID: "u1", Role: entity.RoleMember})
+			return err
+		}},
+		{name: "closed", change: func(pr *entity.PullRequest) error {
+			_, err := prs.ClosePR(ctx, pr.PullRequestID, "u1")
+			return err
+		}},
+		{name: "reviewed", change: func(pr *entity.PullRequest) error {
+			_, err := prs.ReviewPR(ctx, pr.PullRequestID, pr.AssignedReviewers[0], entity.ReviewApproved)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, err := prs.CreatePR(ctx, service.CreatePRInput{
+				PullRequestID:   "pr-" + tt.name,
+				PullRequestName: tt.name,
+				AuthorID:        "u1",
+				ActorID:         "u1",
+			})
+			if err != nil {
+				t.Fatalf("create PR: %v", err)
+			}
+			reviewer := pr.AssignedReviewers[0]
+
+			read, err := prRepo.GetOpenByReviewers(ctx, []string{reviewer})
+			if err != nil {
+				t.Fatalf("GetOpenByReviewers(): %v", err)
+			}
+			i := slices.IndexFunc(read, func(p *entity.PullRequest) bool { return p.PullRequestID == pr.PullRequestID })
+			if i < 0 {
+				t.Fatalf("GetOpenByReviewers() did not return %s", pr.PullRequestID)
+			}
+
+			if err := tt.change(pr); err != nil {
+				t.Fatalf("change PR: %v", err)
+			}
+			before, err := prs.GetPR(ctx, pr.PullRequestID)
+			if err != nil {
+				t.Fatalf("GetPR(): %v", err)
+			}
+
+			err = prRepo.ReplaceReviewers(ctx, []repo.ReviewerChange{{
+				PullRequestID: pr.PullRequestID,
+				OldReviewerID: reviewer,
+				NewReviewerID: "u4",
+				Version:       read[i].Version,
+			}}, entity.Change{Actor: "u1", Reason: "reviewer deactivated"})
+			if !errors.Is(err, domain.ErrConflict) {
+				t.Fatalf("ReplaceReviewers() = %v, want a conflict", err)
+			}
+
+			after, err := prs.GetPR(ctx, pr.PullRequestID)
+			if err != nil {
+				t.Fatalf("GetPR(): %v", err)
+			}
+			if after.Version != before.Version || !slices.Equal(after.AssignedReviewers, before.AssignedReviewers) {
+				t.Fatalf("PR changed from %+v to %+v", before, after)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package postgres
+
+import (
+    "sync"
+    "time"
+)
+
+// pruneEvery spaces out the deletes of stale rows by the stores that clean
+// up after themselves.
+const pruneEvery = time.Minute
+
+// pruner tells a store when its next prune is due, once every pruneEvery
+// per replica.
+type pruner struct {
+    mu       sync.Mutex
+    prunedAt time.Time
+}
+
+func newPruner() *pruner {
+    return &pruner{prunedAt: time.Now()}
+}
+
+func (p *pruner) due() bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if time.Since(p.prunedAt) < pruneEvery {
+        return false
+    }
+    p.prunedAt = time.Now()
+    return true
+}
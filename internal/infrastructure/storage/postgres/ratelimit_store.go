@@ -0,0 +1,57 @@
+package postgres
+
+import (
+    "context"
+    "fmt"
+    "time"
+    "github.com/shmul/avito-task/internal/infrastructure/ratelimit"
+)
+
+// rateLimitIdleTTL is how long an untouched bucket row is kept; dropped
+// buckets start out full again.
+const rateLimitIdleTTL = time.Hour
+
+// RateLimitStore keeps token buckets in Postgres so every replica enforces
+// the same limit. Each take is a single upsert, refilled by the database
+// clock.
+type RateLimitStore struct {
+    db     dbtx
+    pruner *pruner
+}
+
+func NewRateLimitStore(s *Storage) *RateLimitStore {
+    return &RateLimitStore{db: s.conn(), pruner: newPruner()}
+}
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+    s.prune(ctx)
+
+    var tokens float64
+    var allowed bool
+    err := s.db.QueryRowContext(ctx, `
+        INSERT INTO rate_limits AS b (key, tokens, allowed, updated_at)
+        VALUES ($1, GREATEST($3::float8 - 1, 0), $3::float8 >= 1, CURRENT_TIMESTAMP)
+        ON CONFLICT (key) DO UPDATE SET
+            tokens = LEAST($3::float8, b.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - b.updated_at) * $2::float8)
+                   - CASE WHEN LEAST($3::float8, b.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - b.updated_at) * $2::float8) >= 1 THEN 1 ELSE 0 END,
+            allowed = LEAST($3::float8, b.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - b.updated_at) * $2::float8) >= 1,
+            updated_at = CURRENT_TIMESTAMP
+        RETURNING tokens, allowed
+    `, key, limit.Rate, limit.Burst).Scan(&tokens, &allowed)
+    if err != nil {
+        return ratelimit.Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+    }
+
+    return ratelimit.NewResult(tokens, allowed, limit), nil
+}
+
+// prune drops idle buckets.
+func (s *RateLimitStore) prune(ctx context.Context) {
+    if !s.pruner.due() {
+        return
+    }
+
+    // best effort, a failed prune is retried next time
+    s.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE updated_at < CURRENT_TIMESTAMP - $1::interval`,
+        fmt.Sprintf("%d seconds", int(rateLimitIdleTTL.Seconds())))
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "github.com/shmul/avito-task/internal/domain"
+    "github.com/shmul/avito-task/internal/domain/entity"
+    "github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type RepositoryRepository struct {
+    db     dbtx
+    tenant string
+}
+
+func NewRepositoryRepository(s *Storage) repo.RepositoryRepository {
+    return &RepositoryRepository{db: s.conn()}
+}
+
+func (r *RepositoryRepository) ForTenant(tenantID string) repo.RepositoryRepository {
+    return &RepositoryRepository{db: r.db, tenant: tenantID}
+}
+
+func (r *RepositoryRepository) Save(ctx context.Context, repository *entity.Repository) error {
+    var mergePolicy sql.NullString
+    if repository.MergePolicy != nil {
+        data, err := json.Marshal(repository.MergePolicy)
+        if err != nil {
+            return fmt.Errorf("failed to encode merge policy: %w", err)
+        }
+        mergePolicy = sql.NullString{String: string(data), Valid: true}
+    }
+
+    tx, err := begin(ctx, r.db)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    _, err = tx.ExecContext(ctx, `
+        INSERT INTO repositories (tenant_id, repository_id, reviewer_count, strategy, merge_policy)
+        VALUES ($1, $2, $3, $4, $5::jsonb)
+        ON CONFLICT (tenant_id, repository_id)
+        DO UPDATE SET reviewer_count = EXCLUDED.reviewer_count,
+                      strategy = EXCLUDED.strategy,
+                      merge_policy = EXCLUDED.merge_policy
+    `, r.tenant, repository.RepositoryID, repository.ReviewerCount, repository.Strategy, mergePolicy)
+    if err != nil {
+        return fmt.Errorf("failed to save repository: %w", err)
+    }
+
+    _, err = tx.ExecContext(ctx, "DELETE FROM repository_teams WHERE tenant_id = $1 AND repository_id = $2", r.tenant, repository.RepositoryID)
+    if err != nil {
+        return fmt.Errorf("failed to clear eligible teams: %w", err)
+    }
+
+    for i, team := range repository.EligibleTeams {
+        _, err := tx.ExecContext(ctx, `
+            INSERT INTO repository_teams (tenant_id, repository_id, team_name, priority)
+            VALUES ($1, $2, $3, $4)
+        `, r.tenant, repository.RepositoryID, team, i)
+        if err != nil {
+            return fmt.Errorf("failed to add eligible team %s: %w", team, err)
+        }
+    }
+
+    return tx.Commit()
+}
+
+func (r *RepositoryRepository) GetByID(ctx context.Context, repositoryID string) (*entity.Repository, error) {
+    repository := entity.Repository{RepositoryID: repositoryID}
+    var reviewerCount sql.NullInt64
+    var mergePolicy []byte
+
+    err := r.db.QueryRowContext(ctx, `
+        SELECT reviewer_count, strategy, merge_policy
+        FROM repositories
+        WHERE tenant_id = $1 AND repository_id = $2
+    `, r.tenant, repositoryID).Scan(&reviewerCount, &repository.Strategy, &mergePolicy)
+    if err == sql.ErrNoRows {
+        return nil, domain.NotFound("repository %s not found", repositoryID)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get repository: %w", err)
+    }
+
+    if reviewerCount.Valid {
+        count := int(reviewerCount.Int64)
+        repository.ReviewerCount = &count
+    }
+    if mergePolicy != nil {
+        repository.MergePolicy = &entity.MergePolicy{}
+        if err := json.Unmarshal(mergePolicy, repository.MergePolicy); err != nil {
+            return nil, fmt.Errorf("failed to decode merge policy: %w", err)
+        }
+    }
+
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT team_name
+        FROM repository_teams
+        WHERE tenant_id = $1 AND repository_id = $2
+        ORDER BY priority
+    `, r.tenant, repositoryID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get eligible teams: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var team string
+        if err := rows.Scan(&team); err != nil {
+            return nil, fmt.Errorf("failed to scan eligible team: %w", err)
+        }
+        repository.EligibleTeams = append(repository.EligibleTeams, team)
+    }
+
+    return &repository, rows.Err()
+}
@@ -2,18 +2,24 @@
 package postgres
 
 import (
-    "database/sql"
+    "context"
     "fmt"
+    "github.com/shmul/avito-task/internal/domain"
     "github.com/shmul/avito-task/internal/domain/entity"
     "github.com/shmul/avito-task/internal/domain/repo"
 )
 
 type TeamRepository struct {
-    db *sql.DB
+    db     dbtx
+    tenant string
 }
 
-func NewTeamRepository(db *sql.DB) repo.TeamRepository {
-    return &TeamRepository{db: db}
+func NewTeamRepository(s *Storage) repo.TeamRepository {
+    return &TeamRepository{db: s.conn()}
+}
+
+func (r *TeamRepository) ForTenant(tenantID string) repo.TeamRepository {
+    return &TeamRepository{db: r.db, tenant: tenantID}
 }
 
 //для тестов
@@ -22,53 +28,58 @@ func NewTeamRepository(db *sql.DB) repo.TeamRepository {
 // }
 //
 
-func (r *TeamRepository) Create(team *entity.Team) error {
-    tx, err := r.db.Begin()
+func (r *TeamRepository) Create(ctx context.Context, team *entity.Team) error {
+    tx, err := begin(ctx, r.db)
     if err != nil {
         return err
     }
     defer tx.Rollback()
 
-    _, err = tx.Exec("INSERT INTO teams (team_name) VALUES ($1) ON CONFLICT (team_name) DO NOTHING", team.TeamName)
+    _, err = tx.ExecContext(ctx, "INSERT INTO teams (tenant_id, team_name) VALUES ($1, $2) ON CONFLICT (tenant_id, team_name) DO NOTHING", r.tenant, team.TeamName)
     if err != nil {
         return fmt.Errorf("failed to create team: %w", err)
     }
 
     for _, member := range team.Members {
-        _, err = tx.Exec(`
-            INSERT INTO users (user_id, username, team_name, is_active)
-            VALUES ($1, $2, $3, $4)
-            ON CONFLICT (user_id) 
+        _, err = tx.ExecContext(ctx, `
+            INSERT INTO users (tenant_id, user_id, username, team_name, is_active, max_open_reviews)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            ON CONFLICT (tenant_id, user_id) 
             DO UPDATE SET 
                 username = EXCLUDED.username,
                 team_name = EXCLUDED.team_name,
                 is_active = EXCLUDED.is_active,
+                max_open_reviews = EXCLUDED.max_open_reviews,
                 updated_at = CURRENT_TIMESTAMP
-        `, member.UserID, member.Username, team.TeamName, member.IsActive)
+        `, r.tenant, member.UserID, member.Username, team.TeamName, member.IsActive, member.MaxOpenReviews)
         if err != nil {
             return fmt.Errorf("failed to create user %s: %w", member.UserID, err)
         }
     }
 
+    if err := insertFallbacks(ctx, tx, r.tenant, team.TeamName, team.FallbackTeams); err != nil {
+        return err
+    }
+
     return tx.Commit()
 }
 
-func (r *TeamRepository) GetByName(teamName string) (*entity.Team, error) {
+func (r *TeamRepository) GetByName(ctx context.Context, teamName string) (*entity.Team, error) {
     var exists bool
-    err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM teams WHERE team_name = $1)", teamName).Scan(&exists)
+    err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM teams WHERE tenant_id = $1 AND team_name = $2)", r.tenant, teamName).Scan(&exists)
     if err != nil {
         return nil, fmt.Errorf("failed to check team existence: %w", err)
     }
     if !exists {
-        return nil, fmt.Errorf("team not found: %s", teamName)
+        return nil, domain.NotFound("team %s not found", teamName)
     }
 
-    rows, err := r.db.Query(`
-        SELECT user_id, username, team_name, is_active
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT user_id, username, team_name, is_active, max_open_reviews
         FROM users 
-        WHERE team_name = $1
+        WHERE tenant_id = $1 AND team_name = $2
         ORDER BY user_id
-    `, teamName)
+    `, r.tenant, teamName)
     if err != nil {
         return nil, fmt.Errorf("failed to get team members: %w", err)
     }
@@ -76,32 +87,89 @@ func (r *TeamRepository) GetByName(teamName string) (*entity.Team, error) {
 
     var members []entity.User
     for rows.Next() {
-        var user entity.User
-        if err := rows.Scan(
-            &user.UserID,
-            &user.Username,
-            &user.TeamName,
-            &user.IsActive,
-        ); err != nil {
+        user, err := scanUser(rows)
+        if err != nil {
             return nil, fmt.Errorf("failed to scan user: %w", err)
         }
-        members = append(members, user)
+        members = append(members, *user)
     }
 
     if err := rows.Err(); err != nil {
         return nil, fmt.Errorf("error iterating users: %w", err)
     }
 
+    fallbacks, err := r.GetFallbacks(ctx, teamName)
+    if err != nil {
+        return nil, err
+    }
+
     return &entity.Team{
-        TeamName: teamName,
-        Members:  members,
+        TeamName:      teamName,
+        Members:       members,
+        FallbackTeams: fallbacks,
     }, nil
 }
 
-func (r *TeamRepository) Exists(teamName string) (bool, error) {
-    query := `SELECT EXISTS(SELECT 1 FROM teams WHERE team_name = $1)`
+func (r *TeamRepository) Exists(ctx context.Context, teamName string) (bool, error) {
+    query := `SELECT EXISTS(SELECT 1 FROM teams WHERE tenant_id = $1 AND team_name = $2)`
     
     var exists bool
-    err := r.db.QueryRow(query, teamName).Scan(&exists)
+    err := r.db.QueryRowContext(ctx, query, r.tenant, teamName).Scan(&exists)
     return exists, err
+}
+
+func (r *TeamRepository) GetFallbacks(ctx context.Context, teamName string) ([]string, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT fallback_team_name
+        FROM team_fallbacks
+        WHERE tenant_id = $1 AND team_name = $2
+        ORDER BY priority
+    `, r.tenant, teamName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get fallback teams: %w", err)
+    }
+    defer rows.Close()
+
+    var fallbacks []string
+    for rows.Next() {
+        var fallback string
+        if err := rows.Scan(&fallback); err != nil {
+            return nil, fmt.Errorf("failed to scan fallback team: %w", err)
+        }
+        fallbacks = append(fallbacks, fallback)
+    }
+
+    return fallbacks, rows.Err()
+}
+
+func (r *TeamRepository) SetFallbacks(ctx context.Context, teamName string, fallbackTeams []string) error {
+    tx, err := begin(ctx, r.db)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    _, err = tx.ExecContext(ctx, "DELETE FROM team_fallbacks WHERE tenant_id = $1 AND team_name = $2", r.tenant, teamName)
+    if err != nil {
+        return fmt.Errorf("failed to clear fallback teams: %w", err)
+    }
+
+    if err := insertFallbacks(ctx, tx, r.tenant, teamName, fallbackTeams); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+func insertFallbacks(ctx context.Context, tx dbtx, tenant, teamName string, fallbackTeams []string) error {
+    for i, fallback := range fallbackTeams {
+        _, err := tx.ExecContext(ctx, `
+            INSERT INTO team_fallbacks (tenant_id, team_name, fallback_team_name, priority)
+            VALUES ($1, $2, $3, $4)
+        `, tenant, teamName, fallback, i)
+        if err != nil {
+            return fmt.Errorf("failed to add fallback team %s: %w", fallback, err)
+        }
+    }
+    return nil
 }
\ No newline at end of file
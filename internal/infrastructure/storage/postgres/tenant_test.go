@@ -0,0 +1,189 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shmul/avito-task/config"
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/storage/migrations"
+	"github.com/shmul/avito-task/internal/infrastructure/storage/postgres"
+)
+
+// testConfigEnv names a config file whose database the tests may write to,
+// e.g. config/config.yaml against the docker-compose Postgres. Without it
+// the tests are skipped.
+const testConfigEnv = "PR_TEST_CONFIG"
+
+type services struct {
+	teams  *service.TeamService
+	prs    *service.PRService
+	stats  *service.StatsService
+	prRepo repo.PRRepository
+}
+
+// connect opens the test database and migrates it, or skips the test.
+func connect(t *testing.T) *postgres.Storage {
+	t.Helper()
+
+	path := os.Getenv(testConfigEnv)
+	if path == "" {
+		t.Skipf("%s is not set", testConfigEnv)
+	}
+
+	db, err := postgres.NewConnection(config.Load(path))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := migrations.Run(db.DB(), os.DirFS("../../../../cmd/pull-requester")); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func setup(t *testing.T) services {
+	t.Helper()
+
+	db := connect(t)
+	userRepo := postgres.NewUserRepository(db)
+	teamRepo := postgres.NewTeamRepository(db)
+	prRepo := postgres.NewPRRepository(db)
+	prService, err := service.NewPRService(prRepo, userRepo, teamRepo, postgres.NewCodeownersRepository(db), postgres.NewRepositoryRepository(db), postgres.NewTransactor(db), &service.PRServiceConfig{
+		ReviewerCount: 2,
+		RandomSeed:    1,
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("pr service: %v", err)
+	}
+
+	return services{
+		teams:  service.NewTeamService(teamRepo, userRepo),
+		prs:    prService,
+		stats:  service.NewStatsService(prRepo),
+		prRepo: prRepo,
+	}
+}
+
+// TestTenantIsolation seeds one tenant and checks that another tenant,
+// using the same team, user and PR IDs, sees none of it.
+func TestTenantIsolation(t *testing.T) {
+	s := setup(t)
+	ctx := context.Background()
+
+	// the database is shared between runs, so every run gets fresh tenants
+	run := time.Now().UnixNano()
+	tenantA := fmt.Sprintf("a-%d", run)
+	tenantB := fmt.Sprintf("b-%d", run)
+
+	team := &entity.Team{
+		TeamName: "backend",
+		Members: []entity.User{
+			{UserID: "u1", Username: "Alice", IsActive: true},
+			{UserID: "u2", Username: "Bob", IsActive: true},
+			{UserID: "u3", Username: "Carol", IsActive: true},
+		},
+	}
+	if err := s.teams.ForTenant(tenantA).CreateTeam(ctx, team); err != nil {
+		t.Fatalf("create team in A: %v", err)
+	}
+	pr, err := s.prs.ForTenant(tenantA).CreatePR(ctx, service.CreatePRInput{
+		PullRequestID:   "pr-1",
+		PullRequestName: "Add search",
+		AuthorID:        "u1",
+		ActorID:         "u1",
+	})
+	if err != nil {
+		t.Fatalf("create PR in A: %v", err)
+	}
+	if len(pr.AssignedReviewers) == 0 {
+		t.Fatal("PR in A got no reviewers")
+	}
+	reviewer := pr.AssignedReviewers[0]
+
+	teamsB := s.teams.ForTenant(tenantB)
+	prsB := s.prs.ForTenant(tenantB)
+
+	t.Run("read team", func(t *testing.T) {
+		if _, err := teamsB.GetTeam(ctx, "backend"); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("GetTeam in B = %v, want not found", err)
+		}
+	})
+
+	t.Run("read PR", func(t *testing.T) {
+		if _, err := prsB.GetPR(ctx, "pr-1"); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("GetPR in B = %v, want not found", err)
+		}
+		if _, err := prsB.GetHistory(ctx, "pr-1"); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("GetHistory in B = %v, want not found", err)
+		}
+	})
+
+	t.Run("list reviews", func(t *testing.T) {
+		prs, err := prsB.GetPRsByReviewer(ctx, reviewer)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("GetPRsByReviewer in B: %v", err)
+		}
+		if len(prs) != 0 {
+			t.Fatalf("GetPRsByReviewer in B returned %d PRs of A", len(prs))
+		}
+	})
+
+	t.Run("reassign", func(t *testing.T) {
+		if _, err := prsB.ReassignReviewer(ctx, "pr-1", reviewer, ""); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("ReassignReviewer in B = %v, want not found", err)
+		}
+		got, err := s.prs.ForTenant(tenantA).GetPR(ctx, "pr-1")
+		if err != nil {
+			t.Fatalf("GetPR in A: %v", err)
+		}
+		if got.Version != pr.Version {
+			t.Fatalf("PR in A changed from version %d to %d", pr.Version, got.Version)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		reviewers, err := s.stats.ForTenant(tenantB).GetReviewerStats(ctx, entity.StatsFilter{})
+		if err != nil {
+			t.Fatalf("GetReviewerStats in B: %v", err)
+		}
+		if len(reviewers) != 0 {
+			t.Fatalf("GetReviewerStats in B returned %d rows of A", len(reviewers))
+		}
+		teams, err := s.stats.ForTenant(tenantB).GetTeamStats(ctx, entity.StatsFilter{})
+		if err != nil {
+			t.Fatalf("GetTeamStats in B: %v", err)
+		}
+		if len(teams) != 0 {
+			t.Fatalf("GetTeamStats in B returned %d rows of A", len(teams))
+		}
+	})
+
+	t.Run("same IDs", func(t *testing.T) {
+		if err := teamsB.CreateTeam(ctx, team); err != nil {
+			t.Fatalf("create team in B: %v", err)
+		}
+		if _, err := prsB.CreatePR(ctx, service.CreatePRInput{
+			PullRequestID:   "pr-1",
+			PullRequestName: "Other",
+			AuthorID:        "u2",
+		}); err != nil {
+			t.Fatalf("create PR in B: %v", err)
+		}
+		got, err := s.prs.ForTenant(tenantA).GetPR(ctx, "pr-1")
+		if err != nil {
+			t.Fatalf("GetPR in A: %v", err)
+		}
+		if got.PullRequestName != "Add search" || got.AuthorID != "u1" {
+			t.Fatalf("PR in A was overwritten: %+v", got)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// dbtx is satisfied by both a conn and the txScope begun on it, so a
+// repository can run standalone or bound to a Transactor transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*timedRows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *timedRow
+}
+
+// conn runs statements on the pool or on a transaction. Every statement gets
+// its own deadline of timeout on top of the caller's context, so a stuck
+// query gives its connection back even when the caller set no deadline.
+type conn struct {
+	q       queryer
+	timeout time.Duration
+}
+
+func (c *conn) deadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, cancel := c.deadline(ctx)
+	defer cancel()
+	return c.q.ExecContext(ctx, query, args...)
+}
+
+// QueryContext keeps the deadline running until the rows are closed.
+func (c *conn) QueryContext(ctx context.Context, query string, args ...any) (*timedRows, error) {
+	ctx, cancel := c.deadline(ctx)
+	rows, err := c.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timedRows{Rows: rows, cancel: cancel}, nil
+}
+
+// QueryRowContext keeps the deadline running until the row is scanned.
+func (c *conn) QueryRowContext(ctx context.Context, query string, args ...any) *timedRow {
+	ctx, cancel := c.deadline(ctx)
+	return &timedRow{Row: c.q.QueryRowContext(ctx, query, args...), cancel: cancel}
+}
+
+type timedRows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+func (r *timedRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+type timedRow struct {
+	*sql.Row
+	cancel context.CancelFunc
+}
+
+func (r *timedRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+// txScope is the transaction a multi-statement repository method writes
+// through. When the repository is already bound to a transaction, Commit and
+// Rollback are left to whoever opened it.
+type txScope struct {
+	dbtx
+	owned *sql.Tx
+}
+
+func begin(ctx context.Context, db dbtx) (*txScope, error) {
+	c, ok := db.(*conn)
+	if !ok {
+		return &txScope{dbtx: db}, nil
+	}
+	sqlDB, ok := c.q.(*sql.DB)
+	if !ok {
+		return &txScope{dbtx: db}, nil
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &txScope{dbtx: &conn{q: tx, timeout: c.timeout}, owned: tx}, nil
+}
+
+func (t *txScope) Commit() error {
+	if t.owned == nil {
+		return nil
+	}
+	return t.owned.Commit()
+}
+
+func (t *txScope) Rollback() error {
+	if t.owned == nil {
+		return nil
+	}
+	return t.owned.Rollback()
+}
+
+type Transactor struct {
+	db     *conn
+	tenant string
+}
+
+func NewTransactor(s *Storage) repo.Transactor {
+	return &Transactor{db: s.conn()}
+}
+
+func (t *Transactor) ForTenant(tenantID string) repo.Transactor {
+	return &Transactor{db: t.db, tenant: tenantID}
+}
+
+func (t *Transactor) WithinTx(ctx context.Context, fn func(r repo.Repositories) error) error {
+	tx, err := begin(ctx, t.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(repo.Repositories{
+		PRs:   &PRRepository{db: tx, tenant: t.tenant},
+		Users: &UserRepository{db: tx, tenant: t.tenant},
+		Teams: &TeamRepository{db: tx, tenant: t.tenant},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -1,93 +1,118 @@
 package postgres
 
 import (
+    "context"
     "database/sql"
     "fmt"
+    "github.com/shmul/avito-task/internal/domain"
     "github.com/shmul/avito-task/internal/domain/entity"
     "github.com/shmul/avito-task/internal/domain/repo"
 )
 
 type UserRepository struct {
-    db *sql.DB
+    db     dbtx
+    tenant string
 }
 
-func NewUserRepository(db *sql.DB) repo.UserRepository {
-    return &UserRepository{db: db}
+func NewUserRepository(s *Storage) repo.UserRepository {
+    return &UserRepository{db: s.conn()}
 }
 
-func (r *UserRepository) CreateOrUpdate(user *entity.User) error {
+func (r *UserRepository) ForTenant(tenantID string) repo.UserRepository {
+    return &UserRepository{db: r.db, tenant: tenantID}
+}
+
+func (r *UserRepository) CreateOrUpdate(ctx context.Context, user *entity.User) error {
     query := `
-        INSERT INTO users (user_id, username, team_name, is_active)
-        VALUES ($1, $2, $3, $4)
-        ON CONFLICT (user_id) 
+        INSERT INTO users (tenant_id, user_id, username, team_name, is_active, max_open_reviews)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (tenant_id, user_id) 
         DO UPDATE SET 
             username = EXCLUDED.username,
             team_name = EXCLUDED.team_name,
             is_active = EXCLUDED.is_active,
+            max_open_reviews = EXCLUDED.max_open_reviews,
             updated_at = CURRENT_TIMESTAMP
     `
-    _, err := r.db.Exec(query, user.UserID, user.Username, user.TeamName, user.IsActive)
+    _, err := r.db.ExecContext(ctx, query, r.tenant, user.UserID, user.Username, user.TeamName, user.IsActive, user.MaxOpenReviews)
     return err
 }
 
 func (r *UserRepository) GetDB() *sql.DB {
-	return r.db
+	c, ok := r.db.(*conn)
+	if !ok {
+		return nil
+	}
+	db, _ := c.q.(*sql.DB)
+	return db
 }
 
-func (r *UserRepository) GetByID(userID string) (*entity.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, userID string) (*entity.User, error) {
     query := `
-        SELECT user_id, username, team_name, is_active
+        SELECT user_id, username, team_name, is_active, max_open_reviews
         FROM users 
-        WHERE user_id = $1
+        WHERE tenant_id = $1 AND user_id = $2
     `
     
-    var user entity.User
-    err := r.db.QueryRow(query, userID).Scan(
-        &user.UserID,
-        &user.Username, 
-        &user.TeamName,
-        &user.IsActive,
-    )
-    
+    user, err := scanUser(r.db.QueryRowContext(ctx, query, r.tenant, userID))
     if err == sql.ErrNoRows {
-        return nil, fmt.Errorf("user not found: %s", userID)
+        return nil, domain.NotFound("user %s not found", userID)
     }
     
-    return &user, err
+    return user, err
 }
 
-func (r *UserRepository) SetActive(userID string, isActive bool) (*entity.User, error) {
+func (r *UserRepository) SetActive(ctx context.Context, userID string, isActive bool) (*entity.User, error) {
     query := `
         UPDATE users 
         SET is_active = $1, updated_at = CURRENT_TIMESTAMP
-        WHERE user_id = $2
-        RETURNING user_id, username, team_name, is_active
+        WHERE tenant_id = $2 AND user_id = $3
+        RETURNING user_id, username, team_name, is_active, max_open_reviews
     `
     
-    var user entity.User
-    err := r.db.QueryRow(query, isActive, userID).Scan(
-        &user.UserID,
-        &user.Username,
-        &user.TeamName, 
-        &user.IsActive,
-    )
-    
+    user, err := scanUser(r.db.QueryRowContext(ctx, query, isActive, r.tenant, userID))
     if err == sql.ErrNoRows {
-        return nil, fmt.Errorf("user not found: %s", userID)
+        return nil, domain.NotFound("user %s not found", userID)
     }
     
-    return &user, err
+    return user, err
+}
+
+// SetTeamActive flips is_active for userIDs in teamName, or for the whole
+// team when userIDs is empty, and returns the users it matched.
+func (r *UserRepository) SetTeamActive(ctx context.Context, teamName string, userIDs []string, isActive bool) ([]*entity.User, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        UPDATE users
+        SET is_active = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE tenant_id = $2 AND team_name = $3 AND (COALESCE(cardinality($4::text[]), 0) = 0 OR user_id = ANY($4))
+        RETURNING user_id, username, team_name, is_active, max_open_reviews
+    `, isActive, r.tenant, teamName, userIDs)
+    if err != nil {
+        return nil, fmt.Errorf("failed to set team users active: %w", err)
+    }
+    defer rows.Close()
+
+    var users []*entity.User
+    for rows.Next() {
+        user, err := scanUser(rows)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan user: %w", err)
+        }
+        users = append(users, user)
+    }
+
+    return users, rows.Err()
 }
 
-func (r *UserRepository) GetActiveUsersByTeam(teamName string) ([]*entity.User, error) {
+func (r *UserRepository) GetActiveUsersByTeam(ctx context.Context, teamName string) ([]*entity.User, error) {
     query := `
-        SELECT user_id, username, team_name, is_active
+        SELECT user_id, username, team_name, is_active, max_open_reviews
         FROM users 
-        WHERE team_name = $1 AND is_active = true
+        WHERE tenant_id = $1 AND team_name = $2 AND is_active = true
         ORDER BY user_id
     `
     
-    rows, err := r.db.Query(query, teamName)
+    rows, err := r.db.QueryContext(ctx, query, r.tenant, teamName)
     if err != nil {
         return nil, err
     }
@@ -95,30 +120,25 @@ func (r *UserRepository) GetActiveUsersByTeam(teamName string) ([]*entity.User,
     
     var users []*entity.User
     for rows.Next() {
-        var user entity.User
-        if err := rows.Scan(
-            &user.UserID,
-            &user.Username,
-            &user.TeamName,
-            &user.IsActive,
-        ); err != nil {
+        user, err := scanUser(rows)
+        if err != nil {
             return nil, err
         }
-        users = append(users, &user)
+        users = append(users, user)
     }
     
     return users, rows.Err()
 }
 
-func (r *UserRepository) GetByTeam(teamName string) ([]*entity.User, error) {
+func (r *UserRepository) GetByTeam(ctx context.Context, teamName string) ([]*entity.User, error) {
     query := `
-        SELECT user_id, username, team_name, is_active
+        SELECT user_id, username, team_name, is_active, max_open_reviews
         FROM users 
-        WHERE team_name = $1
+        WHERE tenant_id = $1 AND team_name = $2
         ORDER BY user_id
     `
     
-    rows, err := r.db.Query(query, teamName)
+    rows, err := r.db.QueryContext(ctx, query, r.tenant, teamName)
     if err != nil {
         return nil, err
     }
@@ -126,25 +146,76 @@ func (r *UserRepository) GetByTeam(teamName string) ([]*entity.User, error) {
     
     var users []*entity.User
     for rows.Next() {
-        var user entity.User
-        if err := rows.Scan(
-            &user.UserID,
-            &user.Username,
-            &user.TeamName,
-            &user.IsActive,
-        ); err != nil {
+        user, err := scanUser(rows)
+        if err != nil {
             return nil, err
         }
-        users = append(users, &user)
+        users = append(users, user)
     }
     
     return users, rows.Err()
 }
 
-func (r *UserRepository) Exists(userID string) (bool, error) {
-    query := `SELECT EXISTS(SELECT 1 FROM users WHERE user_id = $1)`
+func (r *UserRepository) Exists(ctx context.Context, userID string) (bool, error) {
+    query := `SELECT EXISTS(SELECT 1 FROM users WHERE tenant_id = $1 AND user_id = $2)`
     
     var exists bool
-    err := r.db.QueryRow(query, userID).Scan(&exists)
+    err := r.db.QueryRowContext(ctx, query, r.tenant, userID).Scan(&exists)
     return exists, err
+}
+
+func (r *UserRepository) GetByVCSLogin(ctx context.Context, provider, login string) (*entity.User, error) {
+    query := `
+        SELECT u.user_id, u.username, u.team_name, u.is_active, u.max_open_reviews
+        FROM vcs_accounts a
+        JOIN users u ON u.tenant_id = a.tenant_id AND u.user_id = a.user_id
+        WHERE a.tenant_id = $1 AND a.provider = $2 AND a.login = $3
+    `
+
+    user, err := scanUser(r.db.QueryRowContext(ctx, query, r.tenant, provider, login))
+    if err == sql.ErrNoRows {
+        return nil, domain.NotFound("no user linked to %s login %s", provider, login)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to get user by %s login: %w", provider, err)
+    }
+
+    return user, nil
+}
+
+func (r *UserRepository) LinkVCSAccount(ctx context.Context, provider, login, userID string) error {
+    _, err := r.db.ExecContext(ctx, `
+        INSERT INTO vcs_accounts (tenant_id, provider, login, user_id)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (tenant_id, provider, login) DO UPDATE SET user_id = EXCLUDED.user_id
+    `, r.tenant, provider, login, userID)
+    if err != nil {
+        return fmt.Errorf("failed to link %s account: %w", provider, err)
+    }
+    return nil
+}
+
+type rowScanner interface {
+    Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (*entity.User, error) {
+    var user entity.User
+    var maxOpenReviews sql.NullInt32
+    if err := row.Scan(
+        &user.UserID,
+        &user.Username,
+        &user.TeamName,
+        &user.IsActive,
+        &maxOpenReviews,
+    ); err != nil {
+        return nil, err
+    }
+
+    if maxOpenReviews.Valid {
+        limit := int(maxOpenReviews.Int32)
+        user.MaxOpenReviews = &limit
+    }
+
+    return &user, nil
 }
\ No newline at end of file
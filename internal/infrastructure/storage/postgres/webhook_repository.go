@@ -0,0 +1,189 @@
+package postgres
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "time"
+    "github.com/shmul/avito-task/internal/domain"
+    "github.com/shmul/avito-task/internal/domain/entity"
+    "github.com/shmul/avito-task/internal/domain/repo"
+)
+
+type WebhookRepository struct {
+    db     dbtx
+    tenant string
+}
+
+func NewWebhookRepository(s *Storage) repo.WebhookRepository {
+    return &WebhookRepository{db: s.conn()}
+}
+
+func (r *WebhookRepository) ForTenant(tenantID string) repo.WebhookRepository {
+    return &WebhookRepository{db: r.db, tenant: tenantID}
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, webhook *entity.Webhook) error {
+    eventTypes := webhook.EventTypes
+    if eventTypes == nil {
+        eventTypes = []string{}
+    }
+
+    err := r.db.QueryRowContext(ctx, `
+        INSERT INTO webhooks (tenant_id, url, secret, event_types, is_active)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `, r.tenant, webhook.URL, webhook.Secret, eventTypes, webhook.IsActive).Scan(&webhook.ID, &webhook.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("failed to create webhook: %w", err)
+    }
+    return nil
+}
+
+// GetFailedDeliveries returns dead deliveries and pending ones that have
+// failed at least once, newest first.
+func (r *WebhookRepository) GetFailedDeliveries(ctx context.Context, limit int) ([]*entity.Delivery, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        SELECT d.id, d.webhook_id, d.outbox_id, w.url, w.secret, o.event_type, o.payload,
+               d.status, d.attempts, COALESCE(d.last_error, ''), d.next_attempt_at, d.created_at
+        FROM webhook_deliveries d
+        JOIN webhooks w ON w.id = d.webhook_id
+        JOIN outbox o ON o.id = d.outbox_id
+        WHERE w.tenant_id = $1
+            AND (d.status = 'DEAD' OR (d.status = 'PENDING' AND d.last_error IS NOT NULL))
+        ORDER BY d.id DESC
+        LIMIT $2
+    `, r.tenant, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to get failed deliveries: %w", err)
+    }
+    defer rows.Close()
+
+    return scanDeliveries(rows)
+}
+
+func (r *WebhookRepository) FanOut(ctx context.Context, limit int) (int, error) {
+    var taken int
+    err := r.db.QueryRowContext(ctx, `
+        WITH batch AS (
+            SELECT id, tenant_id, event_type
+            FROM outbox
+            WHERE dispatched_at IS NULL
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        ), fanned AS (
+            INSERT INTO webhook_deliveries (webhook_id, outbox_id)
+            SELECT w.id, b.id
+            FROM batch b
+            JOIN webhooks w ON w.is_active AND w.tenant_id = b.tenant_id
+                AND (cardinality(w.event_types) = 0 OR b.event_type = ANY(w.event_types))
+            ON CONFLICT (webhook_id, outbox_id) DO NOTHING
+        ), dispatched AS (
+            UPDATE outbox
+            SET dispatched_at = CURRENT_TIMESTAMP
+            WHERE id IN (SELECT id FROM batch)
+            RETURNING id
+        )
+        SELECT COUNT(*) FROM dispatched
+    `, limit).Scan(&taken)
+    if err != nil {
+        return 0, fmt.Errorf("failed to fan out outbox: %w", err)
+    }
+    return taken, nil
+}
+
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*entity.Delivery, error) {
+    rows, err := r.db.QueryContext(ctx, `
+        WITH due AS (
+            SELECT id
+            FROM webhook_deliveries
+            WHERE status = 'PENDING' AND next_attempt_at <= CURRENT_TIMESTAMP
+            ORDER BY next_attempt_at, id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        ), claimed AS (
+            UPDATE webhook_deliveries d
+            SET attempts = d.attempts + 1,
+                next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+            FROM due
+            WHERE d.id = due.id
+            RETURNING d.id, d.webhook_id, d.outbox_id, d.status, d.attempts, d.last_error, d.next_attempt_at, d.created_at
+        )
+        SELECT c.id, c.webhook_id, c.outbox_id, w.url, w.secret, o.event_type, o.payload,
+               c.status, c.attempts, COALESCE(c.last_error, ''), c.next_attempt_at, c.created_at
+        FROM claimed c
+        JOIN webhooks w ON w.id = c.webhook_id
+        JOIN outbox o ON o.id = c.outbox_id
+        ORDER BY c.id
+    `, limit, lease.Seconds())
+    if err != nil {
+        return nil, fmt.Errorf("failed to claim deliveries: %w", err)
+    }
+    defer rows.Close()
+
+    return scanDeliveries(rows)
+}
+
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, deliveryID int64, attempts int) error {
+    result, err := r.db.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET status = 'DELIVERED', delivered_at = CURRENT_TIMESTAMP, next_attempt_at = NULL
+        WHERE id = $1 AND attempts = $2 AND status = 'PENDING'
+    `, deliveryID, attempts)
+    if err != nil {
+        return fmt.Errorf("failed to mark delivery %d delivered: %w", deliveryID, err)
+    }
+    return claimHeld(result, deliveryID, attempts)
+}
+
+func (r *WebhookRepository) MarkFailed(ctx context.Context, deliveryID int64, attempts int, lastError string, retryAt *time.Time) error {
+    status := entity.DeliveryPending
+    if retryAt == nil {
+        status = entity.DeliveryDead
+    }
+
+    result, err := r.db.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET status = $3, last_error = $4, next_attempt_at = $5
+        WHERE id = $1 AND attempts = $2 AND status = 'PENDING'
+    `, deliveryID, attempts, status, lastError, retryAt)
+    if err != nil {
+        return fmt.Errorf("failed to mark delivery %d failed: %w", deliveryID, err)
+    }
+    return claimHeld(result, deliveryID, attempts)
+}
+
+// claimHeld fails with a CONFLICT error when an outcome update matched no
+// row, because the delivery was claimed again since attempt attempts.
+func claimHeld(result sql.Result, deliveryID int64, attempts int) error {
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("failed to mark delivery %d: %w", deliveryID, err)
+    }
+    if affected == 0 {
+        return domain.Conflict(domain.CodeConflict, "delivery %d was claimed again after attempt %d", deliveryID, attempts)
+    }
+    return nil
+}
+
+func scanDeliveries(rows *timedRows) ([]*entity.Delivery, error) {
+    deliveries := []*entity.Delivery{}
+    for rows.Next() {
+        var d entity.Delivery
+        var payload []byte
+        var nextAttemptAt sql.NullTime
+        err := rows.Scan(&d.ID, &d.WebhookID, &d.OutboxID, &d.URL, &d.Secret, &d.EventType, &payload,
+            &d.Status, &d.Attempts, &d.LastError, &nextAttemptAt, &d.CreatedAt)
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan delivery: %w", err)
+        }
+        d.Payload = payload
+        if nextAttemptAt.Valid {
+            d.NextAttemptAt = &nextAttemptAt.Time
+        }
+        deliveries = append(deliveries, &d)
+    }
+
+    return deliveries, rows.Err()
+}
@@ -0,0 +1,206 @@
+package vcs_test
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+	"github.com/shmul/avito-task/internal/domain/service"
+)
+
+// store is the in-memory state behind the fake repositories: one team of
+// four users, two of them linked to provider logins.
+type store struct {
+	users   map[string]*entity.User
+	logins  map[string]string
+	prs     map[string]*entity.PullRequest
+	changes []change
+}
+
+// change is one write to a PR, as recorded in its history.
+type change struct {
+	prID   string
+	reason string
+	actor  string
+}
+
+func newStore() *store {
+	s := &store{
+		users:  make(map[string]*entity.User),
+		logins: make(map[string]string),
+		prs:    make(map[string]*entity.PullRequest),
+	}
+	for _, id := range []string{"u1", "u2", "u3", "u4"} {
+		s.users[id] = &entity.User{UserID: id, Username: id, TeamName: "backend", IsActive: true}
+	}
+	s.logins[service.ProviderGitHub+"/octocat"] = "u1"
+	s.logins[service.ProviderGitHub+"/hubot"] = "u2"
+	s.logins[service.ProviderGitLab+"/alice"] = "u1"
+	s.logins[service.ProviderGitLab+"/bob"] = "u2"
+	return s
+}
+
+// vcsService wires a VCSService to s the way main wires it to Postgres.
+func (s *store) vcsService(t *testing.T) *service.VCSService {
+	t.Helper()
+
+	prRepo := &prRepo{s: s}
+	userRepo := &userRepo{s: s}
+	prService, err := service.NewPRService(prRepo, userRepo, teamRepo{}, codeownersRepo{}, repositoryRepo{}, transactor{prRepo, userRepo}, &service.PRServiceConfig{
+		ReviewerCount: 2,
+		RandomSeed:    1,
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("NewPRService(): %v", err)
+	}
+	return service.NewVCSService(prService, prRepo, userRepo)
+}
+
+func (s *store) record(prID string, c entity.Change) {
+	s.changes = append(s.changes, change{prID: prID, reason: c.Reason, actor: c.Actor})
+}
+
+func clonePR(pr *entity.PullRequest) *entity.PullRequest {
+	c := *pr
+	c.AssignedReviewers = slices.Clone(pr.AssignedReviewers)
+	c.FallbackReviewers = slices.Clone(pr.FallbackReviewers)
+	c.ReviewerSources = maps.Clone(pr.ReviewerSources)
+	return &c
+}
+
+// The fakes embed their interface so methods Apply never reaches panic.
+
+type prRepo struct {
+	repo.PRRepository
+	s *store
+}
+
+func (r *prRepo) ForTenant(string) repo.PRRepository { return r }
+
+func (r *prRepo) Exists(_ context.Context, prID string) (bool, error) {
+	_, ok := r.s.prs[prID]
+	return ok, nil
+}
+
+func (r *prRepo) GetByID(_ context.Context, prID string) (*entity.PullRequest, error) {
+	pr, ok := r.s.prs[prID]
+	if !ok {
+		return nil, domain.NotFound("PR %s not found", prID)
+	}
+	return clonePR(pr), nil
+}
+
+func (r *prRepo) Create(_ context.Context, pr *entity.PullRequest, c entity.Change) error {
+	pr.Version = 1
+	r.s.prs[pr.PullRequestID] = clonePR(pr)
+	r.s.record(pr.PullRequestID, c)
+	return nil
+}
+
+func (r *prRepo) Update(_ context.Context, pr *entity.PullRequest, c entity.Change) error {
+	if r.s.prs[pr.PullRequestID].Version != pr.Version {
+		return domain.Conflict(domain.CodeConflict, "PR %s was modified concurrently", pr.PullRequestID)
+	}
+	pr.Version++
+	r.s.prs[pr.PullRequestID] = clonePR(pr)
+	r.s.record(pr.PullRequestID, c)
+	return nil
+}
+
+func (r *prRepo) GetOpenReviewCounts(context.Context, string) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, pr := range r.s.prs {
+		if pr.Status == entity.StatusOpen {
+			for _, reviewerID := range pr.AssignedReviewers {
+				counts[reviewerID]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (r *prRepo) QueueForAssignment(context.Context, string) error { return nil }
+
+func (r *prRepo) GetQueuedForAssignment(context.Context) ([]string, error) { return nil, nil }
+
+func (r *prRepo) RemoveFromAssignmentQueue(context.Context, string) error { return nil }
+
+type userRepo struct {
+	repo.UserRepository
+	s *store
+}
+
+func (r *userRepo) ForTenant(string) repo.UserRepository { return r }
+
+func (r *userRepo) GetByID(_ context.Context, userID string) (*entity.User, error) {
+	user, ok := r.s.users[userID]
+	if !ok {
+		return nil, domain.NotFound("user %s not found", userID)
+	}
+	return user, nil
+}
+
+func (r *userRepo) GetActiveUsersByTeam(_ context.Context, teamName string) ([]*entity.User, error) {
+	var users []*entity.User
+	for _, id := range slices.Sorted(maps.Keys(r.s.users)) {
+		if user := r.s.users[id]; user.TeamName == teamName && user.IsActive {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *userRepo) GetByVCSLogin(ctx context.Context, provider, login string) (*entity.User, error) {
+	userID, ok := r.s.logins[provider+"/"+login]
+	if !ok {
+		return nil, domain.NotFound("no user linked to %s login %s", provider, login)
+	}
+	return r.GetByID(ctx, userID)
+}
+
+type teamRepo struct{ repo.TeamRepository }
+
+func (r teamRepo) ForTenant(string) repo.TeamRepository { return r }
+
+func (teamRepo) GetFallbacks(context.Context, string) ([]string, error) { return nil, nil }
+
+type repositoryRepo struct{ repo.RepositoryRepository }
+
+func (r repositoryRepo) ForTenant(string) repo.RepositoryRepository { return r }
+
+func (repositoryRepo) GetByID(_ context.Context, repositoryID string) (*entity.Repository, error) {
+	return nil, domain.NotFound("repository %s not found", repositoryID)
+}
+
+type codeownersRepo struct{ repo.CodeownersRepository }
+
+func (r codeownersRepo) ForTenant(string) repo.CodeownersRepository { return r }
+
+// transactor hands fn the fake repositories; the store has nothing to roll
+// back.
+type transactor struct {
+	prs   *prRepo
+	users *userRepo
+}
+
+func (t transactor) ForTenant(string) repo.Transactor { return t }
+
+func (t transactor) WithinTx(_ context.Context, fn func(r repo.Repositories) error) error {
+	return fn(repo.Repositories{PRs: t.prs, Users: t.users, Teams: teamRepo{}})
+}
+
+func readFixture(t *testing.T, provider, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", provider, name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
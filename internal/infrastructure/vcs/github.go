@@ -0,0 +1,105 @@
+package vcs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shmul/avito-task/internal/domain/service"
+)
+
+// GitHub webhook headers.
+const (
+	GitHubEventHeader     = "X-GitHub-Event"
+	GitHubSignatureHeader = "X-Hub-Signature-256"
+)
+
+type githubPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Draft  bool   `json:"draft"`
+		Merged bool   `json:"merged"`
+		User   struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		MergedBy *struct {
+			Login string `json:"login"`
+		} `json:"merged_by"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// VerifyGitHub reports whether signature, the X-Hub-Signature-256 header,
+// is the HMAC-SHA256 of body under secret. An empty secret verifies nothing.
+func VerifyGitHub(secret string, body []byte, signature string) bool {
+	if secret == "" {
+		return false
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// ParseGitHub translates a pull_request webhook body. It returns nil for
+// actions that do not affect the PR lifecycle.
+func ParseGitHub(body []byte) (*service.VCSEvent, error) {
+	var payload githubPullRequestEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid github payload: %w", err)
+	}
+	if payload.Repository.FullName == "" || payload.PullRequest.Number == 0 {
+		return nil, fmt.Errorf("invalid github payload: missing repository or pull request")
+	}
+
+	var action service.VCSAction
+	switch payload.Action {
+	case "opened":
+		action = service.VCSOpened
+	case "edited", "synchronize":
+		action = service.VCSUpdated
+	case "ready_for_review":
+		action = service.VCSReady
+	case "reopened":
+		action = service.VCSReopened
+	case "closed":
+		action = service.VCSClosed
+		if payload.PullRequest.Merged {
+			action = service.VCSMerged
+		}
+	default:
+		return nil, nil
+	}
+
+	actor := payload.Sender.Login
+	if payload.PullRequest.MergedBy != nil {
+		actor = payload.PullRequest.MergedBy.Login
+	}
+
+	return &service.VCSEvent{
+		Provider:    service.ProviderGitHub,
+		Project:     payload.Repository.FullName,
+		Number:      payload.PullRequest.Number,
+		Action:      action,
+		Title:       payload.PullRequest.Title,
+		AuthorLogin: payload.PullRequest.User.Login,
+		ActorLogin:  actor,
+		Draft:       payload.PullRequest.Draft,
+	}, nil
+}
@@ -0,0 +1,237 @@
+package vcs_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/vcs"
+)
+
+func TestParseGitHub(t *testing.T) {
+	event := func(number int, action service.VCSAction, title, actor string, draft bool) *service.VCSEvent {
+		return &service.VCSEvent{
+			Provider:    service.ProviderGitHub,
+			Project:     "acme/widgets",
+			Number:      number,
+			Action:      action,
+			Title:       title,
+			AuthorLogin: "octocat",
+			ActorLogin:  actor,
+			Draft:       draft,
+		}
+	}
+
+	tests := []struct {
+		fixture string
+		want    *service.VCSEvent
+	}{
+		{fixture: "opened", want: event(42, service.VCSOpened, "Add search", "octocat", false)},
+		{fixture: "opened_draft", want: event(43, service.VCSOpened, "WIP: Add filters", "octocat", true)},
+		{fixture: "synchronize", want: event(42, service.VCSUpdated, "Add search", "octocat", false)},
+		{fixture: "ready_for_review", want: event(43, service.VCSReady, "Add filters", "octocat", false)},
+		{fixture: "closed", want: event(42, service.VCSClosed, "Add search", "hubot", false)},
+		{fixture: "closed_merged", want: event(42, service.VCSMerged, "Add search", "hubot", false)},
+		{fixture: "reopened", want: event(42, service.VCSReopened, "Add search", "octocat", false)},
+		{fixture: "labeled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := vcs.ParseGitHub(readFixture(t, "github", tt.fixture))
+			if err != nil {
+				t.Fatalf("ParseGitHub(): %v", err)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("ParseGitHub() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Fatalf("ParseGitHub() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGitHubRejects(t *testing.T) {
+	for _, body := range []string{
+		`{`,
+		`{"action":"opened","pull_request":{"number":1}}`,
+		`{"action":"opened","repository":{"full_name":"acme/widgets"}}`,
+	} {
+		if _, err := vcs.ParseGitHub([]byte(body)); err == nil {
+			t.Errorf("ParseGitHub(%s) succeeded", body)
+		}
+	}
+}
+
+func TestVerifyGitHub(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		want      bool
+	}{
+		{name: "valid", secret: "s3cret", signature: signature, want: true},
+		{name: "wrong secret", secret: "other", signature: signature},
+		{name: "no prefix", secret: "s3cret", signature: signature[len("sha256="):]},
+		{name: "empty secret", secret: "", signature: signature},
+		{name: "missing", secret: "s3cret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vcs.VerifyGitHub(tt.secret, body, tt.signature); got != tt.want {
+				t.Fatalf("VerifyGitHub() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// step is one webhook delivery and the PR state expected after it.
+type step struct {
+	fixture string
+	status  entity.PRStatus
+	// changed is whether the delivery wrote to the PR, reason and actor
+	// describe the write
+	changed bool
+	reason  string
+	actor   string
+	wantErr error
+}
+
+func TestApplyGitHub(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opened, closed, reopened and merged",
+			steps: []step{
+				{fixture: "opened", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+				{fixture: "synchronize", status: entity.StatusOpen},
+				{fixture: "closed", status: entity.StatusClosed, changed: true, reason: "closed", actor: "u2"},
+				{fixture: "reopened", status: entity.StatusOpen, changed: true, reason: "reopened", actor: "u1"},
+				{fixture: "closed_merged", status: entity.StatusMerged, changed: true, reason: "merged upstream", actor: "u2"},
+			},
+		},
+		{
+			name: "redelivered events are no-ops",
+			steps: []step{
+				{fixture: "opened", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+				{fixture: "opened", status: entity.StatusOpen},
+				{fixture: "closed", status: entity.StatusClosed, changed: true, reason: "closed", actor: "u2"},
+				{fixture: "closed", status: entity.StatusClosed},
+				{fixture: "reopened", status: entity.StatusOpen, changed: true, reason: "reopened", actor: "u1"},
+				{fixture: "reopened", status: entity.StatusOpen},
+				{fixture: "closed_merged", status: entity.StatusMerged, changed: true, reason: "merged upstream", actor: "u2"},
+				{fixture: "closed_merged", status: entity.StatusMerged},
+			},
+		},
+		{
+			name: "draft marked ready",
+			steps: []step{
+				{fixture: "opened_draft", status: entity.StatusDraft, changed: true, reason: "created", actor: "u1"},
+				{fixture: "ready_for_review", status: entity.StatusOpen, changed: true, reason: "marked ready", actor: "u1"},
+				{fixture: "ready_for_review", status: entity.StatusOpen},
+			},
+		},
+		{
+			name: "first seen on an update",
+			steps: []step{
+				{fixture: "synchronize", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+			},
+		},
+		{
+			name: "merge of an unknown PR is not tracked",
+			steps: []step{
+				{fixture: "closed_merged", wantErr: domain.ErrNotFound},
+			},
+		},
+		{
+			name: "merged PRs are not reopened",
+			steps: []step{
+				{fixture: "opened", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+				{fixture: "closed_merged", status: entity.StatusMerged, changed: true, reason: "merged upstream", actor: "u2"},
+				{fixture: "reopened", status: entity.StatusMerged},
+				{fixture: "closed", status: entity.StatusMerged},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSteps(t, "github", vcs.ParseGitHub, tt.steps)
+		})
+	}
+}
+
+// runSteps parses and applies every step's fixture in order against a fresh
+// store, checking the PR and its history after each.
+func runSteps(t *testing.T, provider string, parse func([]byte) (*service.VCSEvent, error), steps []step) {
+	t.Helper()
+
+	s := newStore()
+	vcsService := s.vcsService(t)
+	for i, st := range steps {
+		event, err := parse(readFixture(t, provider, st.fixture))
+		if err != nil {
+			t.Fatalf("step %d (%s): parse: %v", i, st.fixture, err)
+		}
+		before := len(s.changes)
+
+		pr, err := vcsService.Apply(context.Background(), event)
+		if st.wantErr != nil {
+			if !errors.Is(err, st.wantErr) {
+				t.Fatalf("step %d (%s): Apply() = %v, want %v", i, st.fixture, err, st.wantErr)
+			}
+			if _, ok := s.prs[event.PullRequestID()]; ok {
+				t.Fatalf("step %d (%s): PR was created", i, st.fixture)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("step %d (%s): Apply(): %v", i, st.fixture, err)
+		}
+
+		if pr.Status != st.status {
+			t.Fatalf("step %d (%s): status = %s, want %s", i, st.fixture, pr.Status, st.status)
+		}
+		if pr.AuthorID != "u1" {
+			t.Fatalf("step %d (%s): author = %q, want u1", i, st.fixture, pr.AuthorID)
+		}
+		if pr.Status == entity.StatusOpen && len(pr.AssignedReviewers) != 2 {
+			t.Fatalf("step %d (%s): reviewers = %v, want 2", i, st.fixture, pr.AssignedReviewers)
+		}
+		if pr.Status == entity.StatusMerged && pr.MergedBy != "u2" {
+			t.Fatalf("step %d (%s): merged by %q, want u2", i, st.fixture, pr.MergedBy)
+		}
+
+		written := s.changes[before:]
+		if !st.changed {
+			if len(written) != 0 {
+				t.Fatalf("step %d (%s): wrote %+v, want a no-op", i, st.fixture, written)
+			}
+			continue
+		}
+		if len(written) != 1 {
+			t.Fatalf("step %d (%s): wrote %+v, want one change", i, st.fixture, written)
+		}
+		if got := written[0]; got.reason != st.reason || got.actor != st.actor {
+			t.Fatalf("step %d (%s): change = %+v, want reason %q by %q", i, st.fixture, got, st.reason, st.actor)
+		}
+	}
+}
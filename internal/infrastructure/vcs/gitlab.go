@@ -0,0 +1,97 @@
+package vcs
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shmul/avito-task/internal/domain/service"
+)
+
+// GitLab webhook headers.
+const (
+	GitLabEventHeader = "X-Gitlab-Event"
+	GitLabTokenHeader = "X-Gitlab-Token"
+)
+
+// GitLabMergeRequestHook is the X-Gitlab-Event value of merge request events.
+const GitLabMergeRequestHook = "Merge Request Hook"
+
+type gitlabMergeRequestEvent struct {
+	ObjectKind string `json:"object_kind"`
+	User       struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID            int    `json:"iid"`
+		AuthorID       int    `json:"author_id"`
+		Title          string `json:"title"`
+		Action         string `json:"action"`
+		Draft          bool   `json:"draft"`
+		WorkInProgress bool   `json:"work_in_progress"`
+	} `json:"object_attributes"`
+}
+
+// VerifyGitLab reports whether token, the X-Gitlab-Token header, matches
+// secret. An empty secret verifies nothing.
+func VerifyGitLab(secret, token string) bool {
+	if secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1
+}
+
+// ParseGitLab translates a Merge Request Hook body. It returns nil for
+// actions that do not affect the PR lifecycle, such as approvals.
+//
+// GitLab only sends the author's numeric id, not their username, so the
+// author is only known when the author triggered the event themselves. For
+// events triggered by anyone else AuthorLogin is left empty.
+func ParseGitLab(body []byte) (*service.VCSEvent, error) {
+	var payload gitlabMergeRequestEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid gitlab payload: %w", err)
+	}
+	if payload.ObjectKind != "merge_request" {
+		return nil, nil
+	}
+	if payload.Project.PathWithNamespace == "" || payload.ObjectAttributes.IID == 0 {
+		return nil, fmt.Errorf("invalid gitlab payload: missing project or merge request")
+	}
+
+	var action service.VCSAction
+	switch payload.ObjectAttributes.Action {
+	case "open":
+		action = service.VCSOpened
+	case "update":
+		action = service.VCSUpdated
+	case "merge":
+		action = service.VCSMerged
+	case "close":
+		action = service.VCSClosed
+	case "reopen":
+		action = service.VCSReopened
+	default:
+		return nil, nil
+	}
+
+	var author string
+	if payload.User.ID != 0 && payload.User.ID == payload.ObjectAttributes.AuthorID {
+		author = payload.User.Username
+	}
+
+	return &service.VCSEvent{
+		Provider:    service.ProviderGitLab,
+		Project:     payload.Project.PathWithNamespace,
+		Number:      payload.ObjectAttributes.IID,
+		Action:      action,
+		Title:       payload.ObjectAttributes.Title,
+		AuthorLogin: author,
+		ActorLogin:  payload.User.Username,
+		Draft:       payload.ObjectAttributes.Draft || payload.ObjectAttributes.WorkInProgress,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package vcs_test
+
+import (
+	"testing"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/service"
+	"github.com/shmul/avito-task/internal/infrastructure/vcs"
+)
+
+func TestParseGitLab(t *testing.T) {
+	event := func(action service.VCSAction, author, actor string) *service.VCSEvent {
+		return &service.VCSEvent{
+			Provider:    service.ProviderGitLab,
+			Project:     "acme/widgets",
+			Number:      7,
+			Action:      action,
+			Title:       "Add search",
+			AuthorLogin: author,
+			ActorLogin:  actor,
+		}
+	}
+
+	draft := func(e *service.VCSEvent) *service.VCSEvent {
+		e.Draft = true
+		return e
+	}
+
+	tests := []struct {
+		fixture string
+		want    *service.VCSEvent
+	}{
+		{fixture: "open", want: event(service.VCSOpened, "alice", "alice")},
+		{fixture: "update", want: event(service.VCSUpdated, "alice", "alice")},
+		{fixture: "update_draft", want: draft(event(service.VCSUpdated, "alice", "alice"))},
+		{fixture: "update_by_reviewer", want: event(service.VCSUpdated, "", "bob")},
+		{fixture: "close", want: event(service.VCSClosed, "", "bob")},
+		{fixture: "reopen", want: event(service.VCSReopened, "alice", "alice")},
+		{fixture: "merge", want: event(service.VCSMerged, "", "bob")},
+		{fixture: "approved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := vcs.ParseGitLab(readFixture(t, "gitlab", tt.fixture))
+			if err != nil {
+				t.Fatalf("ParseGitLab(): %v", err)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("ParseGitLab() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Fatalf("ParseGitLab() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyGitLab(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opened, closed, reopened and merged",
+			steps: []step{
+				{fixture: "open", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+				{fixture: "update_by_reviewer", status: entity.StatusOpen},
+				{fixture: "close", status: entity.StatusClosed, changed: true, reason: "closed", actor: "u2"},
+				{fixture: "reopen", status: entity.StatusOpen, changed: true, reason: "reopened", actor: "u1"},
+				{fixture: "merge", status: entity.StatusMerged, changed: true, reason: "merged upstream", actor: "u2"},
+			},
+		},
+		{
+			name: "redelivered events are no-ops",
+			steps: []step{
+				{fixture: "open", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+				{fixture: "open", status: entity.StatusOpen},
+				{fixture: "merge", status: entity.StatusMerged, changed: true, reason: "merged upstream", actor: "u2"},
+				{fixture: "merge", status: entity.StatusMerged},
+			},
+		},
+		{
+			name: "converted to draft and back",
+			steps: []step{
+				{fixture: "open", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+				{fixture: "update_draft", status: entity.StatusDraft, changed: true, reason: "marked draft", actor: "u1"},
+				{fixture: "update_draft", status: entity.StatusDraft},
+				{fixture: "update", status: entity.StatusOpen, changed: true, reason: "marked ready", actor: "u1"},
+			},
+		},
+		{
+			name: "first seen on an update by the author",
+			steps: []step{
+				{fixture: "update", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+			},
+		},
+		{
+			name: "first seen on an update by someone else",
+			steps: []step{
+				{fixture: "update_by_reviewer", wantErr: domain.ErrNotFound},
+				{fixture: "update", status: entity.StatusOpen, changed: true, reason: "created", actor: "u1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSteps(t, "gitlab", vcs.ParseGitLab, tt.steps)
+		})
+	}
+}
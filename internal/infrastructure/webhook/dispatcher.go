@@ -0,0 +1,201 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shmul/avito-task/internal/domain"
+	"github.com/shmul/avito-task/internal/domain/entity"
+	"github.com/shmul/avito-task/internal/domain/repo"
+)
+
+// Headers set on every webhook request.
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// Config tunes a Dispatcher. Every poll claims up to BatchSize deliveries
+// and sends them concurrently.
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+}
+
+// Dispatcher moves outbox events to registered webhooks. Failed deliveries
+// are retried with exponential backoff until MaxAttempts, then marked DEAD.
+type Dispatcher struct {
+	repo   repo.WebhookRepository
+	client *http.Client
+	cfg    Config
+	log    *slog.Logger
+}
+
+func NewDispatcher(repo repo.WebhookRepository, cfg Config, log *slog.Logger) *Dispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 5 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &Dispatcher{
+		repo:   repo,
+		client: &http.Client{Timeout: cfg.Timeout},
+		cfg:    cfg,
+		log:    log,
+	}
+}
+
+// Run dispatches until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	if _, err := d.repo.FanOut(ctx, d.cfg.BatchSize); err != nil {
+		d.log.Error("failed to fan out webhook events", slog.String("error", err.Error()))
+		return
+	}
+
+	// the batch is sent concurrently, so every delivery is done within
+	// Timeout of the claim and its lease only runs out, letting another
+	// dispatcher claim it again, if this one stalls or dies
+	deliveries, err := d.repo.ClaimDue(ctx, d.cfg.BatchSize, 2*d.cfg.Timeout)
+	if err != nil {
+		d.log.Error("failed to claim webhook deliveries", slog.String("error", err.Error()))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Go(func() {
+			d.deliver(ctx, delivery)
+		})
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *entity.Delivery) {
+	err := d.send(ctx, delivery)
+
+	// record the outcome even when shutting down mid-send; an unrecorded
+	// delivery is sent again once its lease runs out
+	ctx = context.WithoutCancel(ctx)
+	if err == nil {
+		if err := d.repo.MarkDelivered(ctx, delivery.ID, delivery.Attempts); err != nil {
+			d.logMarkError("failed to mark webhook delivered", delivery, err)
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if delivery.Attempts < d.cfg.MaxAttempts {
+		at := time.Now().Add(d.backoff(delivery.Attempts))
+		retryAt = &at
+	}
+
+	d.log.Warn("webhook delivery failed",
+		slog.Int64("delivery_id", delivery.ID),
+		slog.Int("attempts", delivery.Attempts),
+		slog.Bool("dead", retryAt == nil),
+		slog.String("error", err.Error()),
+	)
+	if err := d.repo.MarkFailed(ctx, delivery.ID, delivery.Attempts, err.Error(), retryAt); err != nil {
+		d.logMarkError("failed to mark webhook failed", delivery, err)
+	}
+}
+
+// logMarkError reports a failure to record the outcome of delivery. A
+// delivery claimed again after its lease ran out belongs to that claim now,
+// which records its own outcome.
+func (d *Dispatcher) logMarkError(msg string, delivery *entity.Delivery, err error) {
+	if errors.Is(err, domain.ErrConflict) {
+		d.log.Warn("webhook delivery was claimed again before its outcome was recorded",
+			slog.Int64("delivery_id", delivery.ID),
+			slog.Int("attempts", delivery.Attempts),
+		)
+		return
+	}
+	d.log.Error(msg, slog.Int64("delivery_id", delivery.ID), slog.String("error", err.Error()))
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *entity.Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, delivery.Payload))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backoff doubles BaseBackoff for every attempt already made, up to
+// MaxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 1; i < attempts && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
+
+// Sign returns the signature header value for body: the hex HMAC-SHA256 of
+// body keyed with secret, prefixed with "sha256=".
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}